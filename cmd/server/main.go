@@ -6,6 +6,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
@@ -0,0 +1,160 @@
+// Package calendar maps working-day offsets onto dates, skipping non-working
+// weekdays and holidays in a user's timezone.
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+var weekdayNames = [7]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var ErrNoWorkingDays = errors.New("calendar has no working days")
+
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name,omitempty"`
+}
+
+type Calendar struct {
+	Location    *time.Location
+	WorkingDays [7]bool
+	holidays    map[string]bool
+}
+
+// Default is the calendar used when a user has no settings: every day is a
+// working day in UTC, so durations map one-to-one onto calendar days.
+func Default() *Calendar {
+	c := &Calendar{Location: time.UTC}
+	for i := range c.WorkingDays {
+		c.WorkingDays[i] = true
+	}
+	return c
+}
+
+func New(timezone string, workingDays []string, holidays []Holiday) (*Calendar, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", timezone)
+	}
+
+	c := &Calendar{Location: loc}
+	for _, name := range workingDays {
+		day, err := ParseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		c.WorkingDays[day] = true
+	}
+	if !c.hasWorkingDay() {
+		return nil, ErrNoWorkingDays
+	}
+
+	c.holidays = make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		c.holidays[h.Date] = true
+	}
+	return c, nil
+}
+
+func ParseWeekday(name string) (time.Weekday, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	for i, w := range weekdayNames {
+		if n == w || (len(n) > 3 && strings.HasPrefix(strings.ToLower(time.Weekday(i).String()), n)) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+// WorkingDayNames returns the short names of the working weekdays, Sunday first.
+func (c *Calendar) WorkingDayNames() []string {
+	var names []string
+	for i, ok := range c.WorkingDays {
+		if ok {
+			names = append(names, weekdayNames[i])
+		}
+	}
+	return names
+}
+
+// Today returns midnight of the current date in the calendar's timezone.
+func (c *Calendar) Today() time.Time {
+	return c.day(time.Now())
+}
+
+func (c *Calendar) IsWorkingDay(t time.Time) bool {
+	d := c.day(t)
+	return c.WorkingDays[d.Weekday()] && !c.holidays[d.Format(DateLayout)]
+}
+
+// NextWorkingDay returns t's date if it is a working day, otherwise the
+// first working day after it.
+func (c *Calendar) NextWorkingDay(t time.Time) (time.Time, error) {
+	if !c.hasWorkingDay() {
+		return time.Time{}, ErrNoWorkingDays
+	}
+	d := c.day(t)
+	// Each holiday can push the next working day back by at most a week.
+	for i := 0; i < (len(c.holidays)+1)*7; i++ {
+		if c.IsWorkingDay(d) {
+			return d, nil
+		}
+		d = d.AddDate(0, 0, 1)
+	}
+	return time.Time{}, ErrNoWorkingDays
+}
+
+// AddWorkingDays returns the date n working days after the working day start.
+func (c *Calendar) AddWorkingDays(start time.Time, n int) (time.Time, error) {
+	d, err := c.NextWorkingDay(start)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for ; n > 0; n-- {
+		d, err = c.NextWorkingDay(d.AddDate(0, 0, 1))
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return d, nil
+}
+
+func (c *Calendar) hasWorkingDay() bool {
+	for _, ok := range c.WorkingDays {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Calendar) day(t time.Time) time.Time {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// SortHolidays orders holidays by date and drops duplicate dates, keeping the
+// first name seen.
+func SortHolidays(holidays []Holiday) []Holiday {
+	seen := make(map[string]bool, len(holidays))
+	out := make([]Holiday, 0, len(holidays))
+	for _, h := range holidays {
+		if seen[h.Date] {
+			continue
+		}
+		seen[h.Date] = true
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	return out
+}
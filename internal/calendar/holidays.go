@@ -0,0 +1,163 @@
+package calendar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxHolidayRangeDays caps multi-day ICS events so a malformed DTEND cannot
+// expand into an unbounded holiday list.
+const maxHolidayRangeDays = 366
+
+// MaxUploadBytes bounds uploaded ICS/JSON holiday files.
+const MaxUploadBytes = 1 << 20
+
+// maxICSLineBytes bounds a single physical ICS line, which cannot be longer
+// than the upload it came from.
+const maxICSLineBytes = MaxUploadBytes
+
+// ParseJSON accepts either an array of dates ("2025-12-25") or an array of
+// {"date": "...", "name": "..."} objects.
+func ParseJSON(data []byte) ([]Holiday, error) {
+	var dates []string
+	if err := json.Unmarshal(data, &dates); err == nil {
+		holidays := make([]Holiday, 0, len(dates))
+		for _, d := range dates {
+			holidays = append(holidays, Holiday{Date: d})
+		}
+		return validate(holidays)
+	}
+
+	var holidays []Holiday
+	if err := json.Unmarshal(data, &holidays); err != nil {
+		return nil, fmt.Errorf("holidays must be a JSON array of dates or {date, name} objects")
+	}
+	return validate(holidays)
+}
+
+// ParseICS reads all-day and timed VEVENTs from an iCalendar file. Each date
+// covered by an event becomes a holiday; recurrence rules are not expanded.
+func ParseICS(r io.Reader) ([]Holiday, error) {
+	var holidays []Holiday
+	var inEvent bool
+	var start, end, summary string
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		name, value := splitProperty(line)
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent = true
+			start, end, summary = "", "", ""
+		case line == "END:VEVENT":
+			inEvent = false
+			if start == "" {
+				continue
+			}
+			days, err := eventDays(start, end)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range days {
+				holidays = append(holidays, Holiday{Date: d, Name: summary})
+			}
+		case !inEvent:
+		case name == "DTSTART":
+			start = value
+		case name == "DTEND":
+			end = value
+		case name == "SUMMARY":
+			summary = unescapeText(value)
+		}
+	}
+
+	return validate(holidays)
+}
+
+// unfold joins RFC 5545 continuation lines, which start with a space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxICSLineBytes)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ics: %w", err)
+	}
+	return lines, nil
+}
+
+// splitProperty returns the property name without parameters and its value,
+// e.g. "DTSTART;VALUE=DATE:20251225" becomes ("DTSTART", "20251225").
+func splitProperty(line string) (string, string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return line, ""
+	}
+	name := line[:colon]
+	if semi := strings.Index(name, ";"); semi >= 0 {
+		name = name[:semi]
+	}
+	return strings.ToUpper(name), line[colon+1:]
+}
+
+func eventDays(start, end string) ([]string, error) {
+	first, err := parseICSDate(start)
+	if err != nil {
+		return nil, err
+	}
+	if end == "" {
+		return []string{first.Format(DateLayout)}, nil
+	}
+	last, err := parseICSDate(end)
+	if err != nil {
+		return nil, err
+	}
+
+	// DTEND is exclusive; a same-day end still covers the start date.
+	var days []string
+	for d := first; d.Before(last) || d.Equal(first); d = d.AddDate(0, 0, 1) {
+		if len(days) >= maxHolidayRangeDays {
+			return nil, fmt.Errorf("event starting %s spans too many days", first.Format(DateLayout))
+		}
+		days = append(days, d.Format(DateLayout))
+	}
+	return days, nil
+}
+
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`).Replace(s)
+}
+
+func validate(holidays []Holiday) ([]Holiday, error) {
+	for _, h := range holidays {
+		if _, err := time.Parse(DateLayout, h.Date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q", h.Date)
+		}
+	}
+	return SortHolidays(holidays), nil
+}
@@ -0,0 +1,106 @@
+package calendar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Holiday
+		wantErr bool
+	}{
+		{
+			name: "dates",
+			data: `["2025-12-26", "2025-12-25", "2025-12-25"]`,
+			want: []Holiday{{Date: "2025-12-25"}, {Date: "2025-12-26"}},
+		},
+		{
+			name: "objects",
+			data: `[{"date": "2026-01-01", "name": "New Year"}, {"date": "2025-12-25"}]`,
+			want: []Holiday{{Date: "2025-12-25"}, {Date: "2026-01-01", Name: "New Year"}},
+		},
+		{name: "empty", data: `[]`, want: []Holiday{}},
+		{name: "invalid date", data: `["2025-13-01"]`, wantErr: true},
+		{name: "not an array", data: `{"date": "2025-12-25"}`, wantErr: true},
+		{name: "malformed", data: `[`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	ics := func(lines ...string) string {
+		return "BEGIN:VCALENDAR\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+	}
+	tests := []struct {
+		name    string
+		data    string
+		want    []Holiday
+		wantErr bool
+	}{
+		{
+			name: "all-day event",
+			data: ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20251225", "DTEND;VALUE=DATE:20251226", "SUMMARY:Christmas Day", "END:VEVENT"),
+			want: []Holiday{{Date: "2025-12-25", Name: "Christmas Day"}},
+		},
+		{
+			name: "multi-day event",
+			data: ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20251224", "DTEND;VALUE=DATE:20251227", "SUMMARY:Break", "END:VEVENT"),
+			want: []Holiday{{Date: "2025-12-24", Name: "Break"}, {Date: "2025-12-25", Name: "Break"}, {Date: "2025-12-26", Name: "Break"}},
+		},
+		{
+			name: "timed event without end",
+			data: ics("BEGIN:VEVENT", "DTSTART:20260101T090000Z", "SUMMARY:New Year", "END:VEVENT"),
+			want: []Holiday{{Date: "2026-01-01", Name: "New Year"}},
+		},
+		{
+			name: "folded and escaped summary",
+			data: ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250704", "SUMMARY:Independence\\, ", " Day", "END:VEVENT"),
+			want: []Holiday{{Date: "2025-07-04", Name: "Independence, Day"}},
+		},
+		{
+			name: "properties outside events are ignored",
+			data: ics("DTSTART:20250101", "BEGIN:VEVENT", "SUMMARY:No date", "END:VEVENT"),
+			want: nil,
+		},
+		{
+			name:    "invalid date",
+			data:    ics("BEGIN:VEVENT", "DTSTART:2025", "END:VEVENT"),
+			wantErr: true,
+		},
+		{
+			name:    "event spanning too many days",
+			data:    ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250101", "DTEND;VALUE=DATE:20270101", "END:VEVENT"),
+			wantErr: true,
+		},
+		{
+			name:    "line longer than an upload",
+			data:    ics("BEGIN:VEVENT", "SUMMARY:"+strings.Repeat("x", maxICSLineBytes), "END:VEVENT"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseICS(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseICS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(got)+len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseICS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,52 +2,220 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
 type Config struct {
-	Port                 string
-	DatabaseURL          string
-	Auth0Domain          string
-	Auth0Aud             string
-	Auth0Issuer          string
-	Auth0ClientID        string
-	Auth0ClientSecret    string
-	Auth0ManagementToken string
-	Auth0RedirectURI     string
-	FrontendURL          string
-	GeminiKey            string
-	GeminiURL            string
-	Env                  string
-	AllowedOrigins       string
+	Port                      string
+	DatabaseURL               string
+	Auth0Domain               string
+	Auth0Aud                  string
+	Auth0Issuer               string
+	Auth0ClientID             string
+	Auth0ClientSecret         string
+	Auth0ManagementToken      string
+	AuthRedirectURI           string
+	FrontendURL               string
+	GeminiKey                 string
+	GeminiURL                 string
+	LLMProvider               string
+	LLMModel                  string
+	LLMTimeoutSeconds         int
+	LLMFallbackEnabled        bool
+	LLMPrices                 string
+	LLMMaxRetries             int
+	LLMRetryBaseMillis        int
+	LLMRetryMaxMillis         int
+	LLMCircuitThreshold       int
+	LLMCircuitCooldownSeconds int
+	OpenAIKey                 string
+	OpenAIURL                 string
+	OllamaURL                 string
+	Env                       string
+	AllowedOrigins            string
+	DependencyMode            string
+	DeadlineMode              string
+	GenerationCacheTTLSeconds int
+	GenerationCacheSize       int
+	RateLimitRequests         int
+	RateLimitPeriodSeconds    int
+	GenerationQuotas          string
+	QuotaTierClaim            string
+	TrustProxy                bool
+	ProxyHeader               string
+	AdminSubjects             string
+	AuthStateSecret           string
+	AuthConnections           string
+	SessionTTLDays            int
+	SessionCookieDomain       string
+	// OIDCIssuers are the identity providers users can sign in with. The
+	// first one is the default.
+	OIDCIssuers []OIDCIssuer
+}
+
+// OIDCIssuer configures one OpenID Connect identity provider. Its
+// endpoints and signing keys come from the issuer's discovery document.
+type OIDCIssuer struct {
+	// Name identifies the issuer in requests, e.g. /auth/login?issuer=keycloak.
+	Name string
+	// Preset adapts the flow to the provider: "auth0", "keycloak" or
+	// "generic".
+	Preset       string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Audience is the aud access tokens must carry. When empty, tokens must
+	// have been issued to ClientID.
+	Audience string
+	// Connections maps login providers to upstream identity providers,
+	// "provider=connection,...". Supported by the auth0 and keycloak
+	// presets.
+	Connections string
 }
 
 func Load() *Config {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		Port:                 os.Getenv("APP_PORT"),
-		DatabaseURL:          os.Getenv("DATABASE_URL"),
-		Auth0Domain:          os.Getenv("AUTH0_DOMAIN"),
-		Auth0Aud:             os.Getenv("AUTH0_AUDIENCE"),
-		Auth0Issuer:          os.Getenv("AUTH0_ISSUER"),
-		Auth0ClientID:        os.Getenv("AUTH0_CLIENT_ID"),
-		Auth0ClientSecret:    os.Getenv("AUTH0_CLIENT_SECRET"),
-		Auth0ManagementToken: os.Getenv("AUTH0_MANAGEMENT_TOKEN"),
-		Auth0RedirectURI:     os.Getenv("AUTH0_REDIRECT_URI"),
-		FrontendURL:          os.Getenv("FRONTEND_URL"),
-		GeminiKey:            os.Getenv("GEMINI_API_KEY"),
-		GeminiURL:            os.Getenv("GEMINI_BASE_URL"),
-		Env:                  os.Getenv("APP_ENV"),
-		AllowedOrigins:       os.Getenv("ALLOWED_ORIGINS"),
-	}
-
-	if cfg.DatabaseURL == "" || cfg.GeminiKey == "" {
+		Port:                      os.Getenv("APP_PORT"),
+		DatabaseURL:               os.Getenv("DATABASE_URL"),
+		Auth0Domain:               os.Getenv("AUTH0_DOMAIN"),
+		Auth0Aud:                  os.Getenv("AUTH0_AUDIENCE"),
+		Auth0Issuer:               os.Getenv("AUTH0_ISSUER"),
+		Auth0ClientID:             os.Getenv("AUTH0_CLIENT_ID"),
+		Auth0ClientSecret:         os.Getenv("AUTH0_CLIENT_SECRET"),
+		Auth0ManagementToken:      os.Getenv("AUTH0_MANAGEMENT_TOKEN"),
+		AuthRedirectURI:           getEnv("AUTH_REDIRECT_URI", os.Getenv("AUTH0_REDIRECT_URI")),
+		FrontendURL:               os.Getenv("FRONTEND_URL"),
+		GeminiKey:                 os.Getenv("GEMINI_API_KEY"),
+		GeminiURL:                 os.Getenv("GEMINI_BASE_URL"),
+		LLMProvider:               getEnv("LLM_PROVIDER", "gemini"),
+		LLMModel:                  os.Getenv("LLM_MODEL"),
+		LLMTimeoutSeconds:         getEnvInt("LLM_TIMEOUT_SECONDS", 60),
+		LLMFallbackEnabled:        getEnvBool("LLM_FALLBACK_ENABLED", true),
+		LLMPrices:                 os.Getenv("LLM_PRICES"),
+		LLMMaxRetries:             getEnvInt("LLM_MAX_RETRIES", 2),
+		LLMRetryBaseMillis:        getEnvInt("LLM_RETRY_BASE_MS", 500),
+		LLMRetryMaxMillis:         getEnvInt("LLM_RETRY_MAX_MS", 8000),
+		LLMCircuitThreshold:       getEnvInt("LLM_CIRCUIT_THRESHOLD", 5),
+		LLMCircuitCooldownSeconds: getEnvInt("LLM_CIRCUIT_COOLDOWN_SECONDS", 30),
+		OpenAIKey:                 os.Getenv("OPENAI_API_KEY"),
+		OpenAIURL:                 os.Getenv("OPENAI_BASE_URL"),
+		OllamaURL:                 os.Getenv("OLLAMA_BASE_URL"),
+		Env:                       os.Getenv("APP_ENV"),
+		AllowedOrigins:            os.Getenv("ALLOWED_ORIGINS"),
+		DependencyMode:            getEnv("PLAN_DEPENDENCY_MODE", "repair"),
+		DeadlineMode:              getEnv("PLAN_DEADLINE_MODE", "warn"),
+		GenerationCacheTTLSeconds: getEnvInt("GENERATION_CACHE_TTL_SECONDS", 86400),
+		GenerationCacheSize:       getEnvInt("GENERATION_CACHE_SIZE", 500),
+		RateLimitRequests:         getEnvInt("RATE_LIMIT_REQUESTS", 10),
+		RateLimitPeriodSeconds:    getEnvInt("RATE_LIMIT_PERIOD_SECONDS", 1),
+		GenerationQuotas:          getEnv("GENERATION_QUOTAS", "anonymous=20,user=100"),
+		QuotaTierClaim:            os.Getenv("QUOTA_TIER_CLAIM"),
+		TrustProxy:                getEnvBool("TRUST_PROXY", false),
+		ProxyHeader:               getEnv("PROXY_HEADER", "X-Forwarded-For"),
+		AdminSubjects:             os.Getenv("ADMIN_SUBJECTS"),
+		AuthStateSecret:           os.Getenv("AUTH_STATE_SECRET"),
+		AuthConnections:           getEnv("AUTH_CONNECTIONS", "google=google-oauth2,github=github"),
+		SessionTTLDays:            getEnvInt("SESSION_TTL_DAYS", 30),
+		SessionCookieDomain:       os.Getenv("SESSION_COOKIE_DOMAIN"),
+	}
+	cfg.OIDCIssuers = loadOIDCIssuers(cfg)
+
+	if cfg.DatabaseURL == "" {
 		logger, _ := zap.NewProduction()
 		logger.Fatal("missing required environment variables")
 	}
+	if len(cfg.OIDCIssuers) > 0 && cfg.AuthSecret() == "" {
+		logger, _ := zap.NewProduction()
+		logger.Fatal("AUTH_STATE_SECRET is required when the default issuer has no client secret")
+	}
 
 	return cfg
 }
+
+// AuthSecret is the secret the login state and stored tokens are keyed
+// from: AUTH_STATE_SECRET, or the client secret of the default issuer when
+// that is not set. Load refuses to start with issuers but no secret.
+func (c *Config) AuthSecret() string {
+	if c.AuthStateSecret != "" || len(c.OIDCIssuers) == 0 {
+		return c.AuthStateSecret
+	}
+	return c.OIDCIssuers[0].ClientSecret
+}
+
+// loadOIDCIssuers reads the issuers named in OIDC_ISSUERS from their
+// OIDC_<NAME>_* variables. The AUTH0_* variables, when set, add an issuer
+// named "auth0" with the auth0 preset. OIDC_DEFAULT_ISSUER picks the
+// default; otherwise it is the first one.
+func loadOIDCIssuers(cfg *Config) []OIDCIssuer {
+	var issuers []OIDCIssuer
+	if cfg.Auth0Domain != "" {
+		issuer := cfg.Auth0Issuer
+		if issuer == "" {
+			issuer = "https://" + cfg.Auth0Domain + "/"
+		}
+		issuers = append(issuers, OIDCIssuer{
+			Name:         "auth0",
+			Preset:       "auth0",
+			Issuer:       issuer,
+			ClientID:     cfg.Auth0ClientID,
+			ClientSecret: cfg.Auth0ClientSecret,
+			Audience:     cfg.Auth0Aud,
+			Connections:  cfg.AuthConnections,
+		})
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_ISSUERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		issuers = append(issuers, OIDCIssuer{
+			Name:         name,
+			Preset:       getEnv(prefix+"PRESET", "generic"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Audience:     os.Getenv(prefix + "AUDIENCE"),
+			Connections:  os.Getenv(prefix + "CONNECTIONS"),
+		})
+	}
+
+	if def := os.Getenv("OIDC_DEFAULT_ISSUER"); def != "" {
+		for i, issuer := range issuers {
+			if issuer.Name == def {
+				copy(issuers[1:i+1], issuers[:i])
+				issuers[0] = issuer
+				break
+			}
+		}
+	}
+	return issuers
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
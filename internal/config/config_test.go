@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestAuthSecret(t *testing.T) {
+	public := OIDCIssuer{Name: "okta"}
+	confidential := OIDCIssuer{Name: "auth0", ClientSecret: "client-secret"}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "state secret", cfg: Config{AuthStateSecret: "state-secret", OIDCIssuers: []OIDCIssuer{confidential}}, want: "state-secret"},
+		{name: "default issuer secret", cfg: Config{OIDCIssuers: []OIDCIssuer{confidential, public}}, want: "client-secret"},
+		{name: "public default issuer", cfg: Config{OIDCIssuers: []OIDCIssuer{public, confidential}}, want: ""},
+		{name: "no issuers", cfg: Config{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.AuthSecret(); got != tt.want {
+				t.Fatalf("AuthSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
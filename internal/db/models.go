@@ -1,6 +1,9 @@
 package db
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID        string    `json:"id" validate:"required,uuid4"`
@@ -11,11 +14,93 @@ type User struct {
 }
 
 type Plan struct {
-	ID        string      `json:"id" validate:"required,uuid4"`
-	UserID    string      `json:"user_id" validate:"required,uuid4"`
-	Title     string      `json:"title" validate:"required,min=1,max=200"`
-	Goal      string      `json:"goal" validate:"required,min=1,max=1000"`
-	PlanJSON  interface{} `json:"plan_json" validate:"required"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID        string    `json:"id" validate:"required,uuid4"`
+	UserID    string    `json:"user_id" validate:"required,uuid4"`
+	Title     string    `json:"title" validate:"required,min=1,max=200"`
+	Goal      string    `json:"goal" validate:"required,min=1,max=1000"`
+	Tasks     []Task    `json:"plan" validate:"required,min=1,dive"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Task struct {
+	ID           string     `json:"id"`
+	PlanID       string     `json:"-"`
+	ParentID     *string    `json:"parent_id"`
+	Position     int        `json:"position"`
+	Title        string     `json:"task" validate:"required,min=1,max=500"`
+	DurationDays int        `json:"duration_days" validate:"min=1"`
+	DependsOn    []string   `json:"depends_on"`
+	DependsOnIDs []string   `json:"depends_on_ids"`
+	Status       string     `json:"status"`
+	StartedAt    *time.Time `json:"started_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+const (
+	TaskStatusTodo       = "todo"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusBlocked    = "blocked"
+	TaskStatusDone       = "done"
+)
+
+func IsValidTaskStatus(status string) bool {
+	switch status {
+	case TaskStatusTodo, TaskStatusInProgress, TaskStatusBlocked, TaskStatusDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// PlanRevision is an immutable snapshot of a plan's title, goal and tasks,
+// taken after every change. Revisions are numbered from 1 per plan.
+type PlanRevision struct {
+	PlanID       string         `json:"plan_id"`
+	Revision     int            `json:"revision"`
+	Title        string         `json:"title"`
+	Goal         string         `json:"goal"`
+	Tasks        []RevisionTask `json:"plan,omitempty"`
+	Author       string         `json:"author,omitempty"`
+	Reason       string         `json:"reason"`
+	Instruction  string         `json:"instruction,omitempty"`
+	RestoredFrom *int           `json:"restored_from,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// RevisionTask is the stored form of a task inside a revision snapshot.
+type RevisionTask struct {
+	ID           string   `json:"id"`
+	ParentID     *string  `json:"parent_id,omitempty"`
+	Title        string   `json:"task"`
+	DurationDays int      `json:"duration_days"`
+	DependsOn    []string `json:"depends_on"`
+	Status       string   `json:"status"`
+}
+
+const (
+	RevisionInitial    = "initial"
+	RevisionGenerate   = "generate"
+	RevisionEdit       = "edit"
+	RevisionTaskUpdate = "task_update"
+	RevisionRefine     = "refine"
+	RevisionRestore    = "restore"
+	RevisionExpand     = "expand"
+)
+
+// Template is a reusable prompt for a kind of goal. Built-in templates have
+// no owner.
+type Template struct {
+	ID                  string          `json:"id"`
+	UserID              *string         `json:"user_id"`
+	Name                string          `json:"name"`
+	Description         string          `json:"description"`
+	Prompt              string          `json:"prompt"`
+	Variables           json.RawMessage `json:"variables"`
+	DefaultDurationDays int             `json:"default_duration_days"`
+	SeedTasks           json.RawMessage `json:"seed_tasks"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
 }
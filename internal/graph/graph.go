@@ -0,0 +1,451 @@
+// Package graph resolves the name-based dependencies of a task list into an
+// index graph and reports dangling references and cycles.
+package graph
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyThreshold is the minimum similarity for a reworded dependency name to
+// be matched to an existing task. Names that differ in a number or a short
+// word are never matched, however similar.
+const FuzzyThreshold = 0.8
+
+type Node struct {
+	Name      string
+	DependsOn []string
+}
+
+type IssueKind string
+
+const (
+	IssueUnknownDependency IssueKind = "unknown_dependency"
+	IssueSelfDependency    IssueKind = "self_dependency"
+	IssueCycle             IssueKind = "cycle"
+	IssueFuzzyMatch        IssueKind = "fuzzy_match"
+	IssueDuplicateName     IssueKind = "duplicate_name"
+)
+
+// Blocking reports whether the issue leaves the graph unusable as-is.
+// Fuzzy matches and duplicate names are resolved deterministically and are
+// informational only.
+func (k IssueKind) Blocking() bool {
+	switch k {
+	case IssueUnknownDependency, IssueSelfDependency, IssueCycle:
+		return true
+	default:
+		return false
+	}
+}
+
+type Issue struct {
+	Kind       IssueKind `json:"kind"`
+	Task       string    `json:"task"`
+	Reference  string    `json:"reference,omitempty"`
+	ResolvedTo string    `json:"resolved_to,omitempty"`
+	Cycle      []string  `json:"cycle,omitempty"`
+}
+
+var ErrCycle = errors.New("dependency graph contains a cycle")
+
+type Graph struct {
+	Nodes []Node
+	// Deps holds, for each node, the indices of the nodes it depends on.
+	Deps   [][]int
+	Issues []Issue
+}
+
+// Resolve matches every dependency name to a node, first exactly, then after
+// normalising case, whitespace and punctuation, and finally by similarity.
+func Resolve(nodes []Node) *Graph {
+	g := &Graph{
+		Nodes: nodes,
+		Deps:  make([][]int, len(nodes)),
+	}
+
+	exact := make(map[string]int, len(nodes))
+	normalized := make(map[string]int, len(nodes))
+	norms := make([]string, len(nodes))
+	for i, n := range nodes {
+		norms[i] = normalize(n.Name)
+		if _, ok := exact[n.Name]; !ok {
+			exact[n.Name] = i
+		}
+		if first, ok := normalized[norms[i]]; ok {
+			g.Issues = append(g.Issues, Issue{Kind: IssueDuplicateName, Task: n.Name, ResolvedTo: nodes[first].Name})
+			continue
+		}
+		normalized[norms[i]] = i
+	}
+
+	for i, n := range nodes {
+		seen := map[int]bool{}
+		for _, ref := range n.DependsOn {
+			j, fuzzy := lookup(ref, exact, normalized, norms)
+			switch {
+			case j < 0:
+				g.Issues = append(g.Issues, Issue{Kind: IssueUnknownDependency, Task: n.Name, Reference: ref})
+				continue
+			case j == i:
+				g.Issues = append(g.Issues, Issue{Kind: IssueSelfDependency, Task: n.Name, Reference: ref})
+				continue
+			case fuzzy:
+				g.Issues = append(g.Issues, Issue{Kind: IssueFuzzyMatch, Task: n.Name, Reference: ref, ResolvedTo: nodes[j].Name})
+			}
+			if !seen[j] {
+				seen[j] = true
+				g.Deps[i] = append(g.Deps[i], j)
+			}
+		}
+	}
+
+	for _, cycle := range g.cycles() {
+		names := make([]string, len(cycle))
+		for k, idx := range cycle {
+			names[k] = nodes[idx].Name
+		}
+		g.Issues = append(g.Issues, Issue{Kind: IssueCycle, Task: names[0], Cycle: names})
+	}
+
+	return g
+}
+
+func lookup(ref string, exact, normalized map[string]int, norms []string) (int, bool) {
+	if j, ok := exact[ref]; ok {
+		return j, false
+	}
+	n := normalize(ref)
+	if j, ok := normalized[n]; ok {
+		return j, false
+	}
+
+	best, bestScore := -1, 0.0
+	for j, candidate := range norms {
+		if !rewording(n, candidate) {
+			continue
+		}
+		if score := similarity(n, candidate); score > bestScore {
+			best, bestScore = j, score
+		}
+	}
+	if bestScore < FuzzyThreshold {
+		return -1, false
+	}
+	return best, true
+}
+
+// Valid reports whether the graph has no blocking issues.
+func (g *Graph) Valid() bool {
+	for _, issue := range g.Issues {
+		if issue.Kind.Blocking() {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockingIssues returns the issues that make the graph invalid.
+func (g *Graph) BlockingIssues() []Issue {
+	var res []Issue
+	for _, issue := range g.Issues {
+		if issue.Kind.Blocking() {
+			res = append(res, issue)
+		}
+	}
+	return res
+}
+
+// Repair returns the nodes with every dependency rewritten to the canonical
+// name of the task it resolved to. Unknown and self references were already
+// dropped during resolution; cycles are broken by removing the edges that
+// close them, visiting tasks in their original order.
+func (g *Graph) Repair() []Node {
+	deps := g.acyclicDeps()
+	out := make([]Node, len(g.Nodes))
+	for i, n := range g.Nodes {
+		names := make([]string, 0, len(deps[i]))
+		for _, j := range deps[i] {
+			names = append(names, g.Nodes[j].Name)
+		}
+		out[i] = Node{Name: n.Name, DependsOn: names}
+	}
+	return out
+}
+
+// TopologicalOrder returns node indices so that every node follows its
+// dependencies. Ties keep the original order.
+func (g *Graph) TopologicalOrder() ([]int, error) {
+	indegree := make([]int, len(g.Nodes))
+	dependents := make([][]int, len(g.Nodes))
+	for i, deps := range g.Deps {
+		indegree[i] = len(deps)
+		for _, j := range deps {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var ready []int
+	for i, d := range indegree {
+		if d == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	order := make([]int, 0, len(g.Nodes))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, k := range dependents[i] {
+			indegree[k]--
+			if indegree[k] == 0 {
+				ready = append(ready, k)
+			}
+		}
+	}
+
+	if len(order) != len(g.Nodes) {
+		return nil, ErrCycle
+	}
+	return order, nil
+}
+
+// cycles returns the strongly connected components with more than one node,
+// each ordered by original position.
+func (g *Graph) cycles() [][]int {
+	n := len(g.Nodes)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	var stack []int
+	var res [][]int
+	next := 0
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.Deps[v] {
+			if index[w] < 0 {
+				connect(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+
+		if low[v] != index[v] {
+			return
+		}
+		var component []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Ints(component)
+			res = append(res, component)
+		}
+	}
+
+	for v := 0; v < n; v++ {
+		if index[v] < 0 {
+			connect(v)
+		}
+	}
+
+	sort.Slice(res, func(a, b int) bool { return res[a][0] < res[b][0] })
+	return res
+}
+
+// acyclicDeps drops back edges found by a depth-first walk in node order.
+func (g *Graph) acyclicDeps() [][]int {
+	const (
+		unvisited = iota
+		active
+		finished
+	)
+	state := make([]int, len(g.Nodes))
+	deps := make([][]int, len(g.Nodes))
+
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = active
+		for _, w := range g.Deps[v] {
+			if state[w] == active {
+				continue
+			}
+			deps[v] = append(deps[v], w)
+			if state[w] == unvisited {
+				visit(w)
+			}
+		}
+		state[v] = finished
+	}
+
+	for v := range g.Nodes {
+		if state[v] == unvisited {
+			visit(v)
+		}
+	}
+	return deps
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// rewording reports whether two normalised names can be the same task
+// worded differently: one adds or drops words, or every word that was
+// replaced is a misspelling of its replacement. Names that differ in a
+// number ("phase 1" / "phase 3") or in a short word ("design ui" / "design
+// api") name different tasks however similar they look.
+func rewording(a, b string) bool {
+	if strings.ReplaceAll(a, " ", "") == strings.ReplaceAll(b, " ", "") {
+		return true
+	}
+	ta, tb := unique(strings.Fields(a)), unique(strings.Fields(b))
+	var onlyA, onlyB []string
+	for t := range ta {
+		if !tb[t] {
+			onlyA = append(onlyA, t)
+		}
+	}
+	for t := range tb {
+		if !ta[t] {
+			onlyB = append(onlyB, t)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	for _, t := range append(onlyA, onlyB...) {
+		if strings.IndexFunc(t, unicode.IsDigit) >= 0 {
+			return false
+		}
+	}
+	if len(onlyA) == 0 || len(onlyB) == 0 {
+		return true
+	}
+	if len(onlyA) != len(onlyB) {
+		return false
+	}
+	// Pair every replaced word with a distinct misspelling of it.
+	used := make([]bool, len(onlyB))
+	for _, t := range onlyA {
+		found := false
+		for k, u := range onlyB {
+			if !used[k] && misspelling(t, u) {
+				used[k], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// misspelling reports whether two different words are close enough in
+// spelling to be a typo of each other. Short words are never: one letter
+// turns "ui" into "api".
+func misspelling(a, b string) bool {
+	return len(a) >= minTypoWordLength && len(b) >= minTypoWordLength && levenshteinRatio(a, b) >= 0.75
+}
+
+// minTypoWordLength is the length below which a changed word is taken to be
+// a different word rather than a typo.
+const minTypoWordLength = 4
+
+// similarity scores two normalised names between 0 and 1, taking the better
+// of the edit-distance ratio and the word overlap.
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	return max(levenshteinRatio(a, b), tokenOverlap(a, b))
+}
+
+func levenshteinRatio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	longest := max(len(ra), len(rb))
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// tokenOverlap compares word sets. A name whose words are all contained in
+// the other ("research topic" / "research the topic") scores just above the
+// threshold; otherwise it is the Jaccard index.
+func tokenOverlap(a, b string) float64 {
+	ta, tb := unique(strings.Fields(a)), unique(strings.Fields(b))
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	jaccard := float64(shared) / float64(union)
+
+	smaller := min(len(ta), len(tb))
+	if smaller >= 2 && shared == smaller {
+		return max(jaccard, 0.9)
+	}
+	return jaccard
+}
+
+func unique(tokens []string) map[string]bool {
+	set := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		set[t] = true
+	}
+	return set
+}
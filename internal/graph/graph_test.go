@@ -0,0 +1,207 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveLookup(t *testing.T) {
+	tasks := []string{"Research topic", "Phase 1", "Design API", "Deploy v1", "Write tests", "Set up database"}
+	tests := []struct {
+		name      string
+		ref       string
+		want      string // "" for an unknown dependency
+		wantFuzzy bool
+	}{
+		{name: "exact", ref: "Research topic", want: "Research topic"},
+		{name: "case and punctuation", ref: "research-topic!", want: "Research topic"},
+		{name: "extra word", ref: "Research the topic", want: "Research topic", wantFuzzy: true},
+		{name: "typo", ref: "Reserach topic", want: "Research topic", wantFuzzy: true},
+		{name: "plural", ref: "Write test", want: "Write tests", wantFuzzy: true},
+		{name: "joined words", ref: "Setup database", want: "Set up database", wantFuzzy: true},
+		{name: "other phase", ref: "Phase 3", want: ""},
+		{name: "other short word", ref: "Design UI", want: ""},
+		{name: "other version", ref: "Deploy v2", want: ""},
+		{name: "unrelated", ref: "Launch marketing campaign", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := make([]Node, 0, len(tasks)+1)
+			for _, name := range tasks {
+				nodes = append(nodes, Node{Name: name})
+			}
+			nodes = append(nodes, Node{Name: "Dependent", DependsOn: []string{tt.ref}})
+			g := Resolve(nodes)
+			last := len(nodes) - 1
+
+			if tt.want == "" {
+				if len(g.Deps[last]) != 0 {
+					t.Fatalf("%q resolved to %q, want unknown", tt.ref, nodes[g.Deps[last][0]].Name)
+				}
+				if g.Valid() || len(g.Issues) != 1 || g.Issues[0].Kind != IssueUnknownDependency {
+					t.Fatalf("issues = %+v, want one unknown_dependency", g.Issues)
+				}
+				return
+			}
+			if len(g.Deps[last]) != 1 || nodes[g.Deps[last][0]].Name != tt.want {
+				t.Fatalf("%q resolved to %v, want %q", tt.ref, g.Deps[last], tt.want)
+			}
+			fuzzy := len(g.Issues) == 1 && g.Issues[0].Kind == IssueFuzzyMatch && g.Issues[0].ResolvedTo == tt.want
+			if fuzzy != tt.wantFuzzy || (!tt.wantFuzzy && len(g.Issues) != 0) {
+				t.Fatalf("issues = %+v, want fuzzy match %v", g.Issues, tt.wantFuzzy)
+			}
+			if !g.Valid() {
+				t.Fatal("graph with a resolved reference is not valid")
+			}
+		})
+	}
+}
+
+func TestResolveIssues(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []Node
+		wantKinds []IssueKind
+		wantValid bool
+	}{
+		{
+			name:      "clean",
+			nodes:     []Node{{Name: "a"}, {Name: "b", DependsOn: []string{"a"}}},
+			wantValid: true,
+		},
+		{
+			name:      "self dependency",
+			nodes:     []Node{{Name: "a", DependsOn: []string{"a"}}},
+			wantKinds: []IssueKind{IssueSelfDependency},
+		},
+		{
+			name:      "duplicate name",
+			nodes:     []Node{{Name: "Plan"}, {Name: "plan"}},
+			wantKinds: []IssueKind{IssueDuplicateName},
+			wantValid: true,
+		},
+		{
+			name: "cycle",
+			nodes: []Node{
+				{Name: "a", DependsOn: []string{"c"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"b"}},
+				{Name: "d", DependsOn: []string{"a"}},
+			},
+			wantKinds: []IssueKind{IssueCycle},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Resolve(tt.nodes)
+			var kinds []IssueKind
+			for _, issue := range g.Issues {
+				kinds = append(kinds, issue.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) {
+				t.Fatalf("issue kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+			if g.Valid() != tt.wantValid {
+				t.Fatalf("Valid() = %v, want %v", g.Valid(), tt.wantValid)
+			}
+		})
+	}
+
+	g := Resolve([]Node{{Name: "a", DependsOn: []string{"c"}}, {Name: "b", DependsOn: []string{"a"}}, {Name: "c", DependsOn: []string{"b"}}})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(g.Issues[0].Cycle, want) {
+		t.Fatalf("cycle = %v, want %v", g.Issues[0].Cycle, want)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []Node
+		want  [][]string
+	}{
+		{
+			name:  "canonical names",
+			nodes: []Node{{Name: "Research topic"}, {Name: "Outline", DependsOn: []string{"research TOPIC", "Research the topic"}}},
+			want:  [][]string{{}, {"Research topic"}},
+		},
+		{
+			name:  "drops unknown and self references",
+			nodes: []Node{{Name: "a", DependsOn: []string{"a", "missing"}}, {Name: "b", DependsOn: []string{"a"}}},
+			want:  [][]string{{}, {"a"}},
+		},
+		{
+			name: "breaks cycles at the closing edge",
+			nodes: []Node{
+				{Name: "a", DependsOn: []string{"c"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"b"}},
+			},
+			want: [][]string{{"c"}, {}, {"b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Resolve(tt.nodes)
+			repaired := g.Repair()
+			got := make([][]string, len(repaired))
+			for i, n := range repaired {
+				if n.Name != tt.nodes[i].Name {
+					t.Fatalf("node %d renamed to %q", i, n.Name)
+				}
+				got[i] = n.DependsOn
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Repair() deps = %v, want %v", got, tt.want)
+			}
+			if !Resolve(repaired).Valid() {
+				t.Fatal("repaired graph is not valid")
+			}
+			if _, err := Resolve(repaired).TopologicalOrder(); err != nil {
+				t.Fatalf("repaired graph has no order: %v", err)
+			}
+		})
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []Node
+		want    []int
+		wantErr error
+	}{
+		{name: "empty", nodes: nil, want: []int{}},
+		{name: "independent keep order", nodes: []Node{{Name: "a"}, {Name: "b"}, {Name: "c"}}, want: []int{0, 1, 2}},
+		{
+			name: "dependencies first",
+			nodes: []Node{
+				{Name: "deploy", DependsOn: []string{"build", "test"}},
+				{Name: "test", DependsOn: []string{"build"}},
+				{Name: "build"},
+			},
+			want: []int{2, 1, 0},
+		},
+		{
+			name:  "ties by position",
+			nodes: []Node{{Name: "c", DependsOn: []string{"a"}}, {Name: "b"}, {Name: "a"}},
+			want:  []int{1, 2, 0},
+		},
+		{
+			name:    "cycle",
+			nodes:   []Node{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			wantErr: ErrCycle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := Resolve(tt.nodes).TopologicalOrder()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TopologicalOrder() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(order, tt.want) {
+				t.Fatalf("TopologicalOrder() = %v, want %v", order, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,8 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"crypto/rand"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"time"
@@ -17,52 +12,81 @@ import (
 
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 )
 
-type Auth0TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-}
-
-type Auth0UserResponse struct {
-	Sub     string `json:"sub"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
-}
-
-type Auth0ErrorResponse struct {
-	Error            string `json:"error"`
-	ErrorDescription string `json:"error_description"`
-}
-
+// LoginHandler starts the authorization code flow with PKCE at the issuer
+// named by the issuer parameter, or the default one. Server-side logins get
+// a signed state cookie holding the state, code verifier and issuer,
+// checked by CallbackHandler. Single-page apps pass their own
+// code_challenge instead, keep the verifier and state themselves, and
+// redeem the code at /auth/exchange. With redirect=true the browser is sent
+// straight to the issuer, which keeps the state cookie first-party.
 func LoginHandler(c *fiber.Ctx) error {
-	provider := c.Query("provider", "google")
-	if provider != "google" && provider != "github" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_provider"})
-	}
-
 	cfg := c.Locals("config").(*config.Config)
-	state := generateState()
-
-	authURL := fmt.Sprintf("https://%s/authorize?"+
-		"response_type=code&"+
-		"client_id=%s&"+
-		"redirect_uri=%s&"+
-		"scope=openid profile email&"+
-		"state=%s&"+
-		"connection=%s",
-		cfg.Auth0Domain,
-		cfg.Auth0ClientID,
-		url.QueryEscape(cfg.Auth0RedirectURI),
-		state,
-		getAuth0Connection(provider))
 
+	issuer := oidcIssuer(c, c.Query("issuer"))
+	if issuer == nil {
+		return invalidIssuer(c)
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"state":                 {randomToken(32)},
+		"code_challenge_method": {"S256"},
+	}
+	if param := issuer.ConnectionParam(); param != "" && len(issuer.Connections) > 0 {
+		provider := c.Query("provider", issuer.Connections[0].Provider)
+		connection := ""
+		providers := make([]string, len(issuer.Connections))
+		for i, conn := range issuer.Connections {
+			providers[i] = conn.Provider
+			if conn.Provider == provider {
+				connection = conn.Connection
+			}
+		}
+		if connection == "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_provider", "providers": providers})
+		}
+		params.Set(param, connection)
+	} else if c.Query("provider") != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_provider", "providers": []string{}})
+	}
+
+	scope := "openid profile email"
+	if c.Locals("sessions").(*services.SessionStore).Enabled() {
+		// Sessions refresh their access token, which takes a refresh token.
+		scope += " offline_access"
+	}
+	params.Set("scope", scope)
+
+	state := params.Get("state")
+	if challenge := c.Query("code_challenge"); challenge != "" {
+		if c.Query("code_challenge_method", "S256") != "S256" || !validPKCEValue(challenge) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_code_challenge"})
+		}
+		params.Set("redirect_uri", cfg.FrontendURL+"/auth/callback")
+		params.Set("code_challenge", challenge)
+	} else {
+		verifier := randomToken(48)
+		setStateCookie(c, cfg, oauthState{State: state, Verifier: verifier, Issuer: issuer.Name, Expires: time.Now().Add(stateTTL).Unix()})
+		params.Set("redirect_uri", cfg.AuthRedirectURI)
+		params.Set("code_challenge", pkceChallenge(verifier))
+	}
+
+	authURL, err := issuer.AuthorizeURL(context.Background(), params)
+	if err != nil {
+		zap.L().Error("OIDC discovery failed", zap.String("issuer", issuer.Name), zap.Error(err))
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "issuer_unavailable", "detail": err.Error()})
+	}
+	if c.QueryBool("redirect") {
+		return c.Redirect(authURL)
+	}
 	return c.JSON(fiber.Map{
 		"auth_url": authURL,
 		"state":    state,
+		"issuer":   issuer.Name,
 	})
 }
 
@@ -70,11 +94,13 @@ func CallbackHandler(c *fiber.Ctx) error {
 	code := c.Query("code")
 	state := c.Query("state")
 	errorParam := c.Query("error")
+	cfg := c.Locals("config").(*config.Config)
 
 	if errorParam != "" {
+		clearStateCookie(c, cfg)
 		errorDesc := c.Query("error_description")
-		zap.L().Error("Auth0 callback error", zap.String("error", errorParam), zap.String("description", errorDesc))
-		return c.Redirect(fmt.Sprintf("%s/auth/error?error=%s", c.Locals("config").(*config.Config).FrontendURL, errorParam))
+		zap.L().Error("OIDC callback error", zap.String("error", errorParam), zap.String("description", errorDesc))
+		return c.Redirect(fmt.Sprintf("%s/auth/error?error=%s", cfg.FrontendURL, url.QueryEscape(errorParam)))
 	}
 
 	if code == "" {
@@ -85,37 +111,56 @@ func CallbackHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "state_parameter_required"})
 	}
 
-	cfg := c.Locals("config").(*config.Config)
+	// The state must come back to the browser that started the login;
+	// anything else is a forged or replayed callback.
+	st, err := verifyStateCookie(c, cfg, state)
+	clearStateCookie(c, cfg)
+	if err != nil {
+		zap.L().Warn("OAuth state verification failed", zap.Error(err), zap.String("ip", c.IP()))
+		return c.Redirect(fmt.Sprintf("%s/auth/error?error=invalid_state", cfg.FrontendURL))
+	}
+
+	issuer := oidcIssuer(c, st.Issuer)
+	if issuer == nil {
+		return c.Redirect(fmt.Sprintf("%s/auth/error?error=invalid_issuer", cfg.FrontendURL))
+	}
 
-	tokenResp, err := exchangeCodeForToken(cfg, code)
+	ctx := context.Background()
+	tokenResp, err := issuer.ExchangeCode(ctx, code, st.Verifier, cfg.AuthRedirectURI)
 	if err != nil {
-		zap.L().Error("Failed to exchange code for token", zap.Error(err))
+		zap.L().Error("Failed to exchange code for token", zap.String("issuer", issuer.Name), zap.Error(err))
 		return c.Redirect(fmt.Sprintf("%s/auth/error?error=token_exchange_failed", cfg.FrontendURL))
 	}
 
-	userInfo, err := getUserInfoFromAuth0(cfg, tokenResp.AccessToken)
+	userInfo, err := issuer.UserInfo(ctx, tokenResp.AccessToken)
 	if err != nil {
-		zap.L().Error("Failed to get user info from Auth0", zap.Error(err))
+		zap.L().Error("Failed to get user info", zap.String("issuer", issuer.Name), zap.Error(err))
 		return c.Redirect(fmt.Sprintf("%s/auth/error?error=user_info_failed", cfg.FrontendURL))
 	}
 
-	user, err := findOrCreateUserFromAuth0(userInfo.Sub, userInfo.Email, userInfo.Name)
+	user, err := findOrCreateUserFromLogin(loginSubject(c, issuer, userInfo.Sub), userInfo.Email, userInfo.Name)
 	if err != nil {
 		zap.L().Error("Failed to create/find user", zap.Error(err))
 		return c.Redirect(fmt.Sprintf("%s/auth/error?error=user_creation_failed", cfg.FrontendURL))
 	}
 
-	successURL := fmt.Sprintf("%s/auth/success?token=%s&user=%s",
-		cfg.FrontendURL,
-		url.QueryEscape(tokenResp.AccessToken),
-		url.QueryEscape(fmt.Sprintf(`{"id":"%s","email":"%s","name":"%s"}`, user.ID, user.Email, user.Name)))
+	// The token itself never goes into a URL, where it would end up in
+	// browser history and server logs; the frontend redeems the code instead.
+	handoffCode, err := createHandoff(ctx, cfg, user.ID, issuer.Name, tokenResp)
+	if err != nil {
+		zap.L().Error("Failed to create login handoff", zap.Error(err))
+		return c.Redirect(fmt.Sprintf("%s/auth/error?error=handoff_failed", cfg.FrontendURL))
+	}
 
-	return c.Redirect(successURL)
+	return c.Redirect(fmt.Sprintf("%s/auth/success?code=%s", cfg.FrontendURL, url.QueryEscape(handoffCode)))
 }
 
 func ExchangeTokenHandler(c *fiber.Ctx) error {
 	type TokenRequest struct {
-		Code string `json:"code" validate:"required"`
+		Code         string `json:"code" validate:"required"`
+		CodeVerifier string `json:"code_verifier"`
+		Issuer       string `json:"issuer"`
+		Session      bool   `json:"session"`
 	}
 
 	var req TokenRequest
@@ -126,42 +171,58 @@ func ExchangeTokenHandler(c *fiber.Ctx) error {
 	if req.Code == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "code_required"})
 	}
+	if req.CodeVerifier != "" && !validPKCEValue(req.CodeVerifier) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_code_verifier"})
+	}
+	if req.Session && !c.Locals("sessions").(*services.SessionStore).Enabled() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "sessions_disabled"})
+	}
 
 	cfg := c.Locals("config").(*config.Config)
+	issuer := oidcIssuer(c, req.Issuer)
+	if issuer == nil {
+		return invalidIssuer(c)
+	}
 
-	tokenResp, err := exchangeCodeForTokenFrontend(cfg, req.Code)
+	ctx := context.Background()
+	tokenResp, err := issuer.ExchangeCode(ctx, req.Code, req.CodeVerifier, cfg.FrontendURL+"/auth/callback")
 	if err != nil {
-		zap.L().Error("Failed to exchange code for token", zap.Error(err))
+		zap.L().Error("Failed to exchange code for token", zap.String("issuer", issuer.Name), zap.Error(err))
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "token_exchange_failed", "detail": err.Error()})
 	}
 
-	userInfo, err := getUserInfoFromAuth0(cfg, tokenResp.AccessToken)
+	userInfo, err := issuer.UserInfo(ctx, tokenResp.AccessToken)
 	if err != nil {
-		zap.L().Error("Failed to get user info from Auth0", zap.Error(err))
+		zap.L().Error("Failed to get user info", zap.String("issuer", issuer.Name), zap.Error(err))
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_info_failed"})
 	}
 
-	user, err := findOrCreateUserFromAuth0(userInfo.Sub, userInfo.Email, userInfo.Name)
+	user, err := findOrCreateUserFromLogin(loginSubject(c, issuer, userInfo.Sub), userInfo.Email, userInfo.Name)
 	if err != nil {
 		zap.L().Error("Failed to create/find user", zap.Error(err))
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_creation_failed"})
 	}
+	userMap := fiber.Map{
+		"id":    user.ID,
+		"email": user.Email,
+		"name":  user.Name,
+	}
 
+	if req.Session {
+		return startSession(c, cfg, user.ID, issuer.Name, tokenResp, userMap)
+	}
 	return c.JSON(fiber.Map{
 		"access_token": tokenResp.AccessToken,
 		"token_type":   tokenResp.TokenType,
 		"expires_in":   tokenResp.ExpiresIn,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
+		"user":         userMap,
 	})
 }
 
 func RefreshTokenHandler(c *fiber.Ctx) error {
 	type RefreshRequest struct {
 		RefreshToken string `json:"refresh_token"`
+		Issuer       string `json:"issuer"`
 	}
 
 	var req RefreshRequest
@@ -173,9 +234,12 @@ func RefreshTokenHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "refresh_token_required"})
 	}
 
-	cfg := c.Locals("config").(*config.Config)
+	issuer := oidcIssuer(c, req.Issuer)
+	if issuer == nil {
+		return invalidIssuer(c)
+	}
 
-	tokenResp, err := refreshAuth0Token(cfg, req.RefreshToken)
+	tokenResp, err := issuer.Refresh(context.Background(), req.RefreshToken)
 	if err != nil {
 		zap.L().Error("Token refresh failed", zap.Error(err))
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_refresh_token"})
@@ -188,13 +252,27 @@ func RefreshTokenHandler(c *fiber.Ctx) error {
 	})
 }
 
+// LogoutHandler ends the session of the request, if any, and returns the
+// issuer's logout URL that ends the login there too.
 func LogoutHandler(c *fiber.Ctx) error {
 	cfg := c.Locals("config").(*config.Config)
+	issuer := oidcIssuer(c, c.Query("issuer"))
+	if issuer == nil {
+		return invalidIssuer(c)
+	}
+
+	if cookie := c.Cookies(services.SessionCookieName); cookie != "" {
+		if err := c.Locals("sessions").(*services.SessionStore).RevokeCookie(context.Background(), cookie); err != nil {
+			zap.L().Error("Failed to end session", zap.Error(err))
+		}
+		middleware.ClearSessionCookie(c, cfg)
+	}
 
-	logoutURL := fmt.Sprintf("https://%s/v2/logout?client_id=%s&returnTo=%s",
-		cfg.Auth0Domain,
-		cfg.Auth0ClientID,
-		url.QueryEscape(cfg.FrontendURL))
+	logoutURL, err := issuer.LogoutURL(context.Background(), cfg.FrontendURL)
+	if err != nil {
+		zap.L().Error("Failed to build logout URL", zap.String("issuer", issuer.Name), zap.Error(err))
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "issuer_unavailable", "detail": err.Error()})
+	}
 
 	return c.JSON(fiber.Map{
 		"logout_url": logoutURL,
@@ -228,109 +306,10 @@ func UserProfileHandler(c *fiber.Ctx) error {
 	})
 }
 
-func exchangeCodeForToken(cfg *config.Config, code string) (*Auth0TokenResponse, error) {
-	url := fmt.Sprintf("https://%s/oauth/token", cfg.Auth0Domain)
-
-	payload := map[string]interface{}{
-		"grant_type":    "authorization_code",
-		"client_id":     cfg.Auth0ClientID,
-		"client_secret": cfg.Auth0ClientSecret,
-		"code":          code,
-		"redirect_uri":  cfg.Auth0RedirectURI,
-	}
-
-	return makeAuth0TokenRequest(url, payload)
-}
-
-func exchangeCodeForTokenFrontend(cfg *config.Config, code string) (*Auth0TokenResponse, error) {
-	url := fmt.Sprintf("https://%s/oauth/token", cfg.Auth0Domain)
-
-	payload := map[string]interface{}{
-		"grant_type":    "authorization_code",
-		"client_id":     cfg.Auth0ClientID,
-		"client_secret": cfg.Auth0ClientSecret,
-		"code":          code,
-		"redirect_uri":  cfg.FrontendURL + "/auth/callback",
-	}
-
-	return makeAuth0TokenRequest(url, payload)
-}
-
-func refreshAuth0Token(cfg *config.Config, refreshToken string) (*Auth0TokenResponse, error) {
-	url := fmt.Sprintf("https://%s/oauth/token", cfg.Auth0Domain)
-
-	payload := map[string]interface{}{
-		"grant_type":    "refresh_token",
-		"client_id":     cfg.Auth0ClientID,
-		"client_secret": cfg.Auth0ClientSecret,
-		"refresh_token": refreshToken,
-	}
-
-	return makeAuth0TokenRequest(url, payload)
-}
-
-func makeAuth0TokenRequest(url string, payload map[string]interface{}) (*Auth0TokenResponse, error) {
-	jsonData, _ := json.Marshal(payload)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		var errorResp Auth0ErrorResponse
-		json.Unmarshal(body, &errorResp)
-		return nil, fmt.Errorf("auth0 error: %s - %s", errorResp.Error, errorResp.ErrorDescription)
-	}
-
-	var tokenResp Auth0TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, err
-	}
-
-	return &tokenResp, nil
-}
-
-func getUserInfoFromAuth0(cfg *config.Config, accessToken string) (*Auth0UserResponse, error) {
-	url := fmt.Sprintf("https://%s/userinfo", cfg.Auth0Domain)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get user info from Auth0")
-	}
-
-	body, _ := io.ReadAll(resp.Body)
-	var userResp Auth0UserResponse
-	if err := json.Unmarshal(body, &userResp); err != nil {
-		return nil, err
-	}
-
-	return &userResp, nil
-}
-
-func findOrCreateUserFromAuth0(auth0ID, email, name string) (*db.User, error) {
+// findOrCreateUserFromLogin returns the user with subject auth0ID,
+// creating it on first login. auth0ID is the issuer-scoped subject from
+// loginSubject.
+func findOrCreateUserFromLogin(auth0ID, email, name string) (*db.User, error) {
 	var user db.User
 	err := db.Pool.QueryRow(context.Background(),
 		"SELECT id, auth0_id, email, name, created_at FROM users WHERE auth0_id=$1",
@@ -357,20 +336,3 @@ func findOrCreateUserFromAuth0(auth0ID, email, name string) (*db.User, error) {
 
 	return &user, nil
 }
-
-func generateState() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
-}
-
-func getAuth0Connection(provider string) string {
-	switch provider {
-	case "google":
-		return "google-oauth2"
-	case "github":
-		return "github"
-	default:
-		return "google-oauth2"
-	}
-}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+type expandTaskReq struct {
+	Instruction string `json:"instruction"`
+}
+
+// ExpandTaskHandler asks the generator to break one task into subtasks and
+// stores them beneath it. The task keeps its own dependencies and dependents;
+// its duration becomes the rolled-up length of its subtasks.
+func ExpandTaskHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req expandTaskReq
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+		}
+	}
+	req.Instruction = strings.TrimSpace(req.Instruction)
+	if len(req.Instruction) > maxInstructionLength {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "instruction_too_long"})
+	}
+
+	planID, taskID := c.Params("id"), c.Params("taskId")
+	var goal string
+	var updatedAt time.Time
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT COALESCE(goal, ''), updated_at FROM plans WHERE id=$1 AND user_id=$2",
+		planID, sub,
+	).Scan(&goal, &updatedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "task_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	loaded, err := loadTasks(context.Background(), []string{planID})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	current := planTasks(loaded, planID)
+	target := -1
+	for i, t := range current {
+		if t.ID == taskID {
+			target = i
+		}
+	}
+	if target < 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "task_not_found"})
+	}
+	if len(childIndex(current)[target]) > 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "task_already_expanded"})
+	}
+
+	existing := generatedFromTasks(current)
+	generator := c.Locals("generator").(services.PlanGenerator)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	result, err := generator.GeneratePlan(ctx, services.PlanRequest{
+		Goal:        goal,
+		Existing:    existing,
+		Instruction: req.Instruction,
+		Expand:      &existing[target],
+	})
+	if err != nil {
+		result = failedGeneration(generator, err)
+	}
+	recordGeneration(sub, planID, generationExpand, result)
+	if status, body := generationError(err); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	if result.Source == services.SourceFallback {
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "expansion_failed", "reason": result.Reason})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(context.Background())
+
+	if status, body := beginPlanChange(context.Background(), tx, planID, sub); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	var lockedAt time.Time
+	if err := tx.QueryRow(context.Background(), "SELECT updated_at FROM plans WHERE id=$1", planID).Scan(&lockedAt); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if !lockedAt.Equal(updatedAt) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "plan_modified"})
+	}
+
+	if err := insertSubtasks(context.Background(), tx, planID, current[target], tasksFromGenerated(result.Tasks)); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := rollUpDurations(context.Background(), tx, planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if _, err := tx.Exec(context.Background(), "UPDATE plans SET updated_at=now() WHERE id=$1", planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	revision, err := recordRevision(context.Background(), tx, planID, revisionMeta{
+		Author:      sub,
+		Reason:      db.RevisionExpand,
+		Instruction: req.Instruction,
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	plan, err := loadPlan(sub, planID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	subtasks := []db.Task{}
+	for _, t := range plan["plan"].([]db.Task) {
+		if t.ParentID != nil && *t.ParentID == taskID {
+			subtasks = append(subtasks, t)
+		}
+	}
+	return c.JSON(fiber.Map{
+		"plan":     plan,
+		"subtasks": subtasks,
+		"revision": revision,
+		"source":   result.Source,
+	})
+}
+
+// insertSubtasks stores subtasks directly after their parent in plan order.
+// Their name-based dependencies are resolved among the subtasks only.
+func insertSubtasks(ctx context.Context, tx pgx.Tx, planID string, parent db.Task, subtasks []db.Task) error {
+	_, err := tx.Exec(ctx,
+		"UPDATE tasks SET position = position + $1 WHERE plan_id=$2 AND position > $3",
+		len(subtasks), planID, parent.Position,
+	)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(subtasks))
+	byName := make(map[string]string, len(subtasks))
+	for i, t := range subtasks {
+		ids[i] = uuid.NewString()
+		if _, ok := byName[t.Title]; !ok {
+			byName[t.Title] = ids[i]
+		}
+	}
+
+	for i, t := range subtasks {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO tasks (id, plan_id, parent_id, position, title, duration_days, status, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7,now(),now())",
+			ids[i], planID, parent.ID, parent.Position+1+i, t.Title, max(t.DurationDays, 1), db.TaskStatusTodo,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, t := range subtasks {
+		for _, name := range t.DependsOn {
+			depID, ok := byName[name]
+			if !ok || depID == ids[i] {
+				continue
+			}
+			_, err := tx.Exec(ctx,
+				"INSERT INTO task_dependencies (task_id, depends_on_id) VALUES ($1,$2) ON CONFLICT DO NOTHING",
+				ids[i], depID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
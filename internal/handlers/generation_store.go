@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// Generation kinds recorded in the generations table.
+const (
+	generationGenerate = "generate"
+	generationRefine   = "refine"
+	generationExpand   = "expand"
+)
+
+// recordGeneration stores the usage of one generation request. userID and
+// planID may be empty. Failures are logged rather than returned: accounting
+// must not fail a request the user has already paid for in waiting time.
+func recordGeneration(userID, planID, kind string, result *services.PlanResult) {
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO generations (user_id, plan_id, kind, source, provider, model,
+		   prompt_tokens, completion_tokens, total_tokens, latency_ms, cost_usd)
+		 VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		userID, planID, kind, string(result.Source), result.Provider, result.Model,
+		result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens,
+		result.Latency.Milliseconds(), result.Usage.CostUSD,
+	)
+	if err != nil {
+		zap.L().Warn("recording generation failed", zap.String("kind", kind), zap.Error(err))
+	}
+}
+
+// failedGeneration describes a generation that ended in err for
+// recordGeneration, with the cost of the provider call when one was made.
+func failedGeneration(generator services.PlanGenerator, err error) *services.PlanResult {
+	result := &services.PlanResult{Source: services.SourceError, Provider: generator.Name(), Model: generator.Model()}
+	var genErr *services.GenerationError
+	if errors.As(err, &genErr) {
+		result.Provider, result.Model = genErr.Provider, genErr.Model
+		result.Usage, result.Latency = genErr.Usage, genErr.Latency
+	}
+	return result
+}
+
+// generationTotals aggregates a set of generations.
+type generationTotals struct {
+	Generations      int     `json:"generations"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens"`
+	CostUSD          float64 `json:"costUsd"`
+	AvgLatencyMs     int     `json:"avgLatencyMs"`
+}
+
+type modelTotals struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	generationTotals
+}
+
+type dayTotals struct {
+	Day string `json:"day"`
+	generationTotals
+}
+
+type userTotals struct {
+	// UserID is empty for anonymous generations.
+	UserID string `json:"userId"`
+	generationTotals
+}
+
+type generationStats struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Totals   generationTotals `json:"totals"`
+	BySource map[string]int   `json:"bySource"`
+	ByModel  []modelTotals    `json:"byModel"`
+	ByDay    []dayTotals      `json:"byDay"`
+	ByUser   []userTotals     `json:"byUser,omitempty"`
+}
+
+const totalsColumns = `count(*), COALESCE(sum(prompt_tokens), 0), COALESCE(sum(completion_tokens), 0),
+	COALESCE(sum(total_tokens), 0), COALESCE(sum(cost_usd), 0)::float8,
+	COALESCE(avg(latency_ms) FILTER (WHERE source = 'llm'), 0)::integer`
+
+// topUsersLimit bounds the per-user breakdown of the global statistics.
+const topUsersLimit = 50
+
+// loadGenerationStats aggregates generations created in [from, to). An
+// empty userID aggregates every user and adds the top users by cost.
+func loadGenerationStats(ctx context.Context, userID string, from, to time.Time) (*generationStats, error) {
+	stats := &generationStats{
+		From:     from.Format("2006-01-02"),
+		To:       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		BySource: map[string]int{},
+		ByModel:  []modelTotals{},
+		ByDay:    []dayTotals{},
+	}
+	where := "created_at >= $1 AND created_at < $2 AND ($3 = '' OR user_id = $3)"
+	args := []any{from, to, userID}
+
+	t := &stats.Totals
+	if err := db.Pool.QueryRow(ctx, "SELECT "+totalsColumns+" FROM generations WHERE "+where, args...).
+		Scan(&t.Generations, &t.PromptTokens, &t.CompletionTokens, &t.TotalTokens, &t.CostUSD, &t.AvgLatencyMs); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, "SELECT source, count(*) FROM generations WHERE "+where+" GROUP BY source", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var source string
+		var n int
+		if err := rows.Scan(&source, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.BySource[source] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Pool.Query(ctx,
+		"SELECT provider, model, "+totalsColumns+" FROM generations WHERE "+where+
+			" GROUP BY provider, model ORDER BY 7 DESC, 3 DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m modelTotals
+		if err := rows.Scan(&m.Provider, &m.Model, &m.Generations, &m.PromptTokens, &m.CompletionTokens,
+			&m.TotalTokens, &m.CostUSD, &m.AvgLatencyMs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.ByModel = append(stats.ByModel, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Pool.Query(ctx,
+		"SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, "+totalsColumns+
+			" FROM generations WHERE "+where+" GROUP BY day ORDER BY day", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d dayTotals
+		if err := rows.Scan(&d.Day, &d.Generations, &d.PromptTokens, &d.CompletionTokens,
+			&d.TotalTokens, &d.CostUSD, &d.AvgLatencyMs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.ByDay = append(stats.ByDay, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if userID != "" {
+		return stats, nil
+	}
+	stats.ByUser = []userTotals{}
+	rows, err = db.Pool.Query(ctx,
+		"SELECT COALESCE(user_id, ''), "+totalsColumns+" FROM generations WHERE "+where+
+			" GROUP BY user_id ORDER BY 6 DESC, 2 DESC LIMIT $4", append(args, topUsersLimit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u userTotals
+		if err := rows.Scan(&u.UserID, &u.Generations, &u.PromptTokens, &u.CompletionTokens,
+			&u.TotalTokens, &u.CostUSD, &u.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		stats.ByUser = append(stats.ByUser, u)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// handoffTTL is how long the frontend has to redeem a login handoff code.
+const handoffTTL = time.Minute
+
+// createHandoff stores the tokens of a finished login under a random
+// single-use code and returns the code. Only a hash of the code is stored
+// and the tokens are encrypted, so a database dump yields nothing usable.
+func createHandoff(ctx context.Context, cfg *config.Config, userID, issuer string, token *services.TokenResponse) (string, error) {
+	code := randomToken(32)
+	accessToken, err := services.SealToken(cfg, token.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	var refreshToken []byte
+	if token.RefreshToken != "" {
+		if refreshToken, err = services.SealToken(cfg, token.RefreshToken); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := db.Pool.Exec(ctx, "DELETE FROM auth_handoffs WHERE expires_at <= now()"); err != nil {
+		return "", err
+	}
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO auth_handoffs (code_hash, user_id, issuer, access_token, refresh_token, token_type, expires_in, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		hashHandoffCode(code), userID, issuer, accessToken, refreshToken, token.TokenType, token.ExpiresIn, time.Now().Add(handoffTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// HandoffHandler redeems the code that CallbackHandler passed to the
+// frontend for the access token and user. A code works once. With
+// "session": true the tokens stay on the server and the browser gets a
+// session cookie instead.
+func HandoffHandler(c *fiber.Ctx) error {
+	var req struct {
+		Code    string `json:"code"`
+		Session bool   `json:"session"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_request_body"})
+	}
+	if req.Code == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "code_required"})
+	}
+	if req.Session && !c.Locals("sessions").(*services.SessionStore).Enabled() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "sessions_disabled"})
+	}
+	cfg := c.Locals("config").(*config.Config)
+	ctx := context.Background()
+
+	var userID, issuer string
+	var token services.TokenResponse
+	var accessToken, refreshToken []byte
+	var expiresAt time.Time
+	err := db.Pool.QueryRow(ctx,
+		`DELETE FROM auth_handoffs WHERE code_hash=$1
+		 RETURNING user_id, issuer, access_token, refresh_token, token_type, expires_in, expires_at`,
+		hashHandoffCode(req.Code),
+	).Scan(&userID, &issuer, &accessToken, &refreshToken, &token.TokenType, &token.ExpiresIn, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && time.Now().After(expiresAt)) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_handoff_code"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	if token.AccessToken, err = services.OpenToken(cfg, accessToken); err == nil && refreshToken != nil {
+		token.RefreshToken, err = services.OpenToken(cfg, refreshToken)
+	}
+	if err != nil {
+		zap.L().Error("Failed to decrypt handoff token", zap.Error(err))
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_handoff_code"})
+	}
+
+	var user db.User
+	err = db.Pool.QueryRow(ctx, "SELECT id, email, name FROM users WHERE id=$1", userID).
+		Scan(&user.ID, &user.Email, &user.Name)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	userMap := fiber.Map{
+		"id":    user.ID,
+		"email": user.Email,
+		"name":  user.Name,
+	}
+
+	c.Set("Cache-Control", "no-store")
+	if req.Session {
+		return startSession(c, cfg, user.ID, issuer, &token, userMap)
+	}
+	resp := fiber.Map{
+		"access_token": token.AccessToken,
+		"token_type":   token.TokenType,
+		"expires_in":   token.ExpiresIn,
+		"user":         userMap,
+	}
+	if token.RefreshToken != "" {
+		resp["refresh_token"] = token.RefreshToken
+	}
+	return c.JSON(resp)
+}
+
+func hashHandoffCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
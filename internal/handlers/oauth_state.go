@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+const (
+	stateCookieName = "oauth_state"
+	// stateTTL is how long a login may take between /auth/login and the
+	// callback.
+	stateTTL = 10 * time.Minute
+)
+
+// oauthState is what the login flow remembers in the browser between the
+// redirect to the issuer and the callback.
+type oauthState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Issuer   string `json:"i"`
+	Expires  int64  `json:"e"`
+}
+
+var errInvalidState = errors.New("invalid oauth state")
+
+// setStateCookie stores st in a signed, HttpOnly cookie scoped to /auth.
+// SameSite=Lax lets it travel with the top-level redirect back from the
+// issuer.
+func setStateCookie(c *fiber.Ctx, cfg *config.Config, st oauthState) {
+	payload, _ := json.Marshal(st)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, services.AuthKey(cfg, "oauth-state"))
+	mac.Write([]byte(encoded))
+	c.Cookie(&fiber.Cookie{
+		Name:     stateCookieName,
+		Value:    encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)),
+		Path:     "/auth",
+		Expires:  time.Unix(st.Expires, 0),
+		HTTPOnly: true,
+		Secure:   cfg.Env != "development",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func clearStateCookie(c *fiber.Ctx, cfg *config.Config) {
+	c.Cookie(&fiber.Cookie{
+		Name:     stateCookieName,
+		Value:    "",
+		Path:     "/auth",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   cfg.Env != "development",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// verifyStateCookie checks the cookie's signature and expiry and that it
+// was issued for state.
+func verifyStateCookie(c *fiber.Ctx, cfg *config.Config, state string) (*oauthState, error) {
+	encoded, sig, ok := strings.Cut(c.Cookies(stateCookieName), ".")
+	if !ok {
+		return nil, errInvalidState
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, errInvalidState
+	}
+	mac := hmac.New(sha256.New, services.AuthKey(cfg, "oauth-state"))
+	mac.Write([]byte(encoded))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, errInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidState
+	}
+	var st oauthState
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, errInvalidState
+	}
+	if time.Now().Unix() > st.Expires {
+		return nil, fmt.Errorf("%w: expired", errInvalidState)
+	}
+	if subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 {
+		return nil, errInvalidState
+	}
+	return &st, nil
+}
+
+// randomToken returns n random bytes, base64url encoded without padding.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		zap.L().Fatal("crypto/rand failed", zap.Error(err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pkceChallenge is the S256 code challenge of verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validPKCEValue reports whether v is a well-formed code verifier or S256
+// challenge: 43 to 128 unreserved characters.
+func validPKCEValue(v string) bool {
+	if len(v) < 43 || len(v) > 128 {
+		return false
+	}
+	for _, r := range v {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("-._~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// oidcIssuer returns the configured issuer called name, or the default
+// issuer for an empty name, and nil when there is no such issuer.
+func oidcIssuer(c *fiber.Ctx, name string) *services.OIDCProvider {
+	return c.Locals("oidc_providers").(*services.OIDCProviders).Get(name)
+}
+
+// loginSubject is the user ID for subject sub at issuer, matching the
+// auth_sub the auth middleware sets for that user's tokens.
+func loginSubject(c *fiber.Ctx, issuer *services.OIDCProvider, sub string) string {
+	return c.Locals("oidc_providers").(*services.OIDCProviders).Subject(issuer, sub)
+}
+
+func invalidIssuer(c *fiber.Ctx) error {
+	issuers := c.Locals("oidc_providers").(*services.OIDCProviders).Names()
+	return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_issuer", "issuers": issuers})
+}
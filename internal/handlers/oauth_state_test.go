@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+)
+
+func TestValidPKCEValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "random token", value: randomToken(32), want: true},
+		{name: "challenge", value: pkceChallenge(randomToken(32)), want: true},
+		{name: "minimum length", value: strings.Repeat("a", 43), want: true},
+		{name: "maximum length", value: strings.Repeat("a", 128), want: true},
+		{name: "unreserved punctuation", value: strings.Repeat("a", 39) + "-._~", want: true},
+		{name: "empty", value: "", want: false},
+		{name: "too short", value: strings.Repeat("a", 42), want: false},
+		{name: "too long", value: strings.Repeat("a", 129), want: false},
+		{name: "padding", value: strings.Repeat("a", 42) + "=", want: false},
+		{name: "plus", value: strings.Repeat("a", 42) + "+", want: false},
+		{name: "space", value: strings.Repeat("a", 42) + " ", want: false},
+		{name: "non ascii", value: strings.Repeat("a", 42) + "é", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validPKCEValue(tt.value); got != tt.want {
+				t.Fatalf("validPKCEValue(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyStateCookie(t *testing.T) {
+	cfg := &config.Config{AuthStateSecret: "test-secret", Env: "development"}
+	otherCfg := &config.Config{AuthStateSecret: "other-secret", Env: "development"}
+
+	// stateCookie returns the cookie setStateCookie issues for st under cfg.
+	stateCookie := func(t *testing.T, cfg *config.Config, st oauthState) string {
+		t.Helper()
+		app := fiber.New()
+		app.Get("/auth/login", func(c *fiber.Ctx) error {
+			setStateCookie(c, cfg, st)
+			return nil
+		})
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == stateCookieName {
+				return cookie.Value
+			}
+		}
+		t.Fatal("no state cookie set")
+		return ""
+	}
+
+	valid := oauthState{State: "abc", Verifier: "verifier", Issuer: "auth0", Expires: time.Now().Add(stateTTL).Unix()}
+	expired := valid
+	expired.Expires = time.Now().Add(-time.Minute).Unix()
+	good := stateCookie(t, cfg, valid)
+	payload, sig, _ := strings.Cut(good, ".")
+
+	tests := []struct {
+		name    string
+		cookie  string
+		state   string
+		wantErr bool
+	}{
+		{name: "valid", cookie: good, state: "abc"},
+		{name: "wrong state", cookie: good, state: "abd", wantErr: true},
+		{name: "empty state", cookie: good, state: "", wantErr: true},
+		{name: "missing cookie", cookie: "", state: "abc", wantErr: true},
+		{name: "no signature", cookie: payload, state: "abc", wantErr: true},
+		{name: "bad signature encoding", cookie: payload + ".!!", state: "abc", wantErr: true},
+		{name: "tampered payload", cookie: "x" + payload + "." + sig, state: "abc", wantErr: true},
+		{name: "other secret", cookie: stateCookie(t, otherCfg, valid), state: "abc", wantErr: true},
+		{name: "expired", cookie: stateCookie(t, cfg, expired), state: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			var got *oauthState
+			var verifyErr error
+			app.Get("/auth/callback", func(c *fiber.Ctx) error {
+				got, verifyErr = verifyStateCookie(c, cfg, tt.state)
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodGet, "/auth/callback", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: stateCookieName, Value: tt.cookie})
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(verifyErr, errInvalidState) {
+					t.Fatalf("verifyStateCookie() error = %v, want errInvalidState", verifyErr)
+				}
+				return
+			}
+			if verifyErr != nil {
+				t.Fatalf("verifyStateCookie() error = %v", verifyErr)
+			}
+			if *got != valid {
+				t.Fatalf("verifyStateCookie() = %+v, want %+v", *got, valid)
+			}
+		})
+	}
+}
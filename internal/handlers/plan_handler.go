@@ -4,20 +4,72 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/validation"
 )
 
 type generateReq struct {
-	Goal  string `json:"goal"`
-	Title string `json:"title"`
+	Goal       string            `json:"goal"`
+	Title      string            `json:"title"`
+	TemplateID string            `json:"template_id"`
+	Variables  map[string]string `json:"variables"`
+	services.GenerationOptions
+}
+
+// planRequest builds the generator request for req, validating its options
+// and resolving its template. It returns a nil body on success.
+func planRequest(c *fiber.Ctx, req *generateReq) (services.PlanRequest, int, fiber.Map) {
+	sub, _ := authSubject(c)
+	opts := req.GenerationOptions
+	if problems := validation.StructErrors(&opts); problems != nil {
+		return services.PlanRequest{}, http.StatusBadRequest, fiber.Map{"error": "invalid_options", "problems": problems}
+	}
+
+	if opts.Deadline != "" {
+		cal := calendar.Default()
+		if sub != "" {
+			var err error
+			if cal, err = loadCalendar(context.Background(), sub); err != nil {
+				return services.PlanRequest{}, http.StatusInternalServerError, fiber.Map{"error": "settings_load_failed", "detail": err.Error()}
+			}
+		}
+		opts.Today = cal.Today()
+		opts.Calendar = cal
+		if opts.DeadlineMode == "" {
+			opts.DeadlineMode = c.Locals("config").(*config.Config).DeadlineMode
+		}
+		deadline, _ := time.ParseInLocation(calendar.DateLayout, opts.Deadline, cal.Location)
+		if deadline.Before(opts.Today) {
+			return services.PlanRequest{}, http.StatusBadRequest, fiber.Map{
+				"error":    "invalid_options",
+				"problems": []validation.FieldError{{Field: "deadline", Message: "deadline must not be in the past"}},
+			}
+		}
+	}
+
+	tmpl, status, body := resolveTemplate(context.Background(), req.TemplateID, sub, req.Goal, req.Variables)
+	if body != nil {
+		return services.PlanRequest{}, status, body
+	}
+	return services.PlanRequest{
+		Goal:        req.Goal,
+		Template:    tmpl,
+		Variables:   req.Variables,
+		Options:     opts,
+		CacheBypass: c.Query("cache") == "bypass",
+	}, http.StatusOK, nil
 }
 
 func GenerateHandler(c *fiber.Ctx) error {
@@ -29,34 +81,55 @@ func GenerateHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "goal_required"})
 	}
 
+	planReq, status, body := planRequest(c, &req)
+	if body != nil {
+		return c.Status(status).JSON(body)
+	}
+	userID, err := generationUser(c)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
+	}
+	generator := c.Locals("generator").(services.PlanGenerator)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
-	tasks, err := services.GeneratePlan(ctx, req.Goal)
+	result, err := generator.GeneratePlan(ctx, planReq)
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "generation_failed", "detail": err.Error()})
+		result = failedGeneration(generator, err)
+	}
+	var planID string
+	defer func() { recordGeneration(userID, planID, generationGenerate, result) }()
+	if status, body := generationError(err); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	if result.Source == services.SourceCache {
+		middleware.RateLimitRefund(c)()
+	}
+	// The fitted result carries the usage of any compression calls, so it
+	// must replace result before anything can return.
+	fitted, fit, err := services.FitDeadline(ctx, generator, planReq, result)
+	if fitted != nil {
+		result = fitted
+	}
+	if err != nil {
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "deadline_check_failed", "detail": err.Error()})
 	}
 
-	response := fiber.Map{"plan": tasks}
-
-	authSub := c.Locals("auth_sub")
-	if authSub != nil {
-		userID := authSub.(string)
-		_, err = findOrCreateUser(userID)
-		if err != nil {
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
-		}
+	response := planResultBody(result)
+	if fit != nil {
+		response["deadlineFit"] = fit
+	}
 
-		id := uuid.NewString()
-		planJson, _ := json.Marshal(tasks)
-		_, err = db.Pool.Exec(context.Background(),
-			"INSERT INTO plans (id, user_id, title, goal, plan_json, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,now(),now())",
-			id, userID, req.Title, req.Goal, planJson,
-		)
+	if result.Source == services.SourceFallback {
+		response["saved"] = false
+		response["message"] = fallbackNotSavedMessage
+	} else if userID != "" {
+		planID, err = savePlan(context.Background(), userID, req.Title, req.Goal, tasksFromGenerated(result.Tasks))
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "save_failed", "detail": err.Error()})
 		}
 
-		response["id"] = id
+		response["id"] = planID
 		response["saved"] = true
 	} else {
 		response["saved"] = false
@@ -66,6 +139,49 @@ func GenerateHandler(c *fiber.Ctx) error {
 	return c.Status(http.StatusOK).JSON(response)
 }
 
+const fallbackNotSavedMessage = "The plan generator is unavailable; this is a template plan and was not saved."
+
+// planResultBody is the part of a generation response that describes where
+// the plan came from.
+func planResultBody(result *services.PlanResult) fiber.Map {
+	body := fiber.Map{"plan": result.Tasks, "source": result.Source}
+	if result.Reason != "" {
+		body["reason"] = result.Reason
+	}
+	return body
+}
+
+// generationError maps a plan generation error to a status and response
+// body. It returns a nil body when err is nil.
+func generationError(err error) (int, fiber.Map) {
+	var depErr *services.DependencyError
+	var validationErr *services.TaskValidationError
+	var templateErr *services.TemplateError
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.As(err, &depErr):
+		return http.StatusUnprocessableEntity, fiber.Map{"error": "invalid_dependencies", "issues": depErr.Issues}
+	case errors.As(err, &validationErr):
+		return http.StatusBadGateway, fiber.Map{"error": "invalid_generation", "problems": validationErr.Problems}
+	case errors.As(err, &templateErr):
+		return http.StatusBadRequest, fiber.Map{"error": "invalid_variables", "problems": templateErr.Problems}
+	default:
+		return http.StatusBadGateway, fiber.Map{"error": "generation_failed", "reason": services.FailureReason(err), "detail": err.Error()}
+	}
+}
+
+// generationUser returns the user a generation request is recorded and
+// saved under, creating the user on first use; it is empty for anonymous
+// callers.
+func generationUser(c *fiber.Ctx) (string, error) {
+	sub, ok := authSubject(c)
+	if !ok {
+		return "", nil
+	}
+	return findOrCreateUser(sub)
+}
+
 func findOrCreateUser(sub string) (string, error) {
 	var id string
 	err := db.Pool.QueryRow(context.Background(), "SELECT id FROM users WHERE auth0_id=$1", sub).Scan(&id)
@@ -86,29 +202,41 @@ func HistoryHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
 	}
 	sub := authSub.(string)
-	rows, err := db.Pool.Query(context.Background(), "SELECT id, title, goal, plan_json, created_at FROM plans WHERE user_id=$1 ORDER BY created_at DESC LIMIT 100", sub)
+	rows, err := db.Pool.Query(context.Background(), "SELECT id, title, goal, created_at, updated_at FROM plans WHERE user_id=$1 ORDER BY created_at DESC LIMIT 100", sub)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
 	}
-	defer rows.Close()
 	var res []map[string]interface{}
+	var ids []string
 	for rows.Next() {
 		var id, title, goal string
-		var planJson []byte
-		var createdAt time.Time
-		if err := rows.Scan(&id, &title, &goal, &planJson, &createdAt); err != nil {
+		var createdAt, updatedAt time.Time
+		if err := rows.Scan(&id, &title, &goal, &createdAt, &updatedAt); err != nil {
 			continue
 		}
-		var plan interface{}
-		_ = json.Unmarshal(planJson, &plan)
+		ids = append(ids, id)
 		res = append(res, map[string]interface{}{
 			"id":        id,
 			"title":     title,
 			"goal":      goal,
-			"plan":      plan,
 			"createdAt": createdAt,
+			"updatedAt": updatedAt,
 		})
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	tasks, err := loadTasks(context.Background(), ids)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	for _, plan := range res {
+		planTaskList := planTasks(tasks, plan["id"].(string))
+		plan["plan"] = planTaskList
+		plan["percentComplete"] = progressPercent(planTaskList)
+	}
 	return c.JSON(fiber.Map{"plans": res})
 }
 
@@ -121,63 +249,345 @@ func GenerateStreamHandler(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "goal_required"})
 	}
 
+	planReq, status, body := planRequest(c, &req)
+	if body != nil {
+		return c.Status(status).JSON(body)
+	}
+	userID, err := generationUser(c)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
+	}
+	generator := c.Locals("generator").(services.PlanGenerator)
+	// The status is already 200 when the outcome is known, so the rate
+	// limiter cannot see rejections and cache hits; they are refunded here.
+	refund := middleware.RateLimitRefund(c)
+
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
-	c.Set("Access-Control-Allow-Origin", "*")
-	c.Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
 
-		writeSSE := func(event, data string) {
+		// A failed flush means the client went away; cancelling ctx aborts the
+		// upstream provider call.
+		writeSSE := func(event, data string) error {
 			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
-			w.Flush()
+			if err := w.Flush(); err != nil {
+				cancel()
+				return err
+			}
+			return nil
+		}
+		writeTask := func(index int, task services.Task) error {
+			data, _ := json.Marshal(streamedTask{Index: index, Task: task})
+			return writeSSE("task", string(data))
 		}
 
 		writeSSE("status", `{"message": "Starting plan generation..."}`)
 
-		tasks, err := services.GeneratePlan(ctx, req.Goal)
+		streamed := 0
+		planReq.OnTask = func(index int, task services.Task) error {
+			streamed++
+			return writeTask(index, task)
+		}
+		result, err := generator.GeneratePlan(ctx, planReq)
 		if err != nil {
-			writeSSE("error", fmt.Sprintf(`{"error": "generation_failed", "detail": "%s"}`, err.Error()))
+			result = failedGeneration(generator, err)
+		}
+		var planID string
+		defer func() { recordGeneration(userID, planID, generationGenerate, result) }()
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+		if status, body := generationError(err); body != nil {
+			if status < http.StatusInternalServerError {
+				refund()
+			}
+			errData, _ := json.Marshal(body)
+			writeSSE("error", string(errData))
+			return
+		}
+		if result.Source == services.SourceCache {
+			refund()
+		}
+
+		// A provider that failed midway has streamed part of a plan that the
+		// fallback replaced; the client must drop those tasks before the
+		// fallback's are sent.
+		if streamed > 0 && result.Source == services.SourceFallback {
+			data, _ := json.Marshal(fiber.Map{"reason": result.Reason})
+			if writeSSE("reset", string(data)) != nil {
+				return
+			}
+			streamed = 0
+		}
+
+		// Providers that cannot stream report the tasks all at once.
+		if streamed == 0 {
+			for i, task := range result.Tasks {
+				if writeTask(i, task) != nil {
+					return
+				}
+			}
+		}
+
+		if planReq.Options.Deadline != "" && planReq.Options.DeadlineMode == services.DeadlineModeCompress {
+			writeSSE("status", `{"message": "Checking the plan against the deadline..."}`)
+		}
+		// The fitted result carries the usage of any compression calls, so it
+		// must replace result before anything can return, including a client
+		// that went away mid-compression.
+		fitted, fit, err := services.FitDeadline(ctx, generator, planReq, result)
+		if fitted != nil {
+			result = fitted
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+		if err != nil {
+			errData, _ := json.Marshal(fiber.Map{"error": "deadline_check_failed", "detail": err.Error()})
+			writeSSE("error", string(errData))
 			return
 		}
 
 		writeSSE("progress", `{"message": "Plan generated successfully!"}`)
 
-		planData, _ := json.Marshal(fiber.Map{"plan": tasks})
+		planBody := planResultBody(result)
+		if fit != nil {
+			fitData, _ := json.Marshal(fit)
+			writeSSE("deadline", string(fitData))
+			planBody["deadlineFit"] = fit
+		}
+		planData, _ := json.Marshal(planBody)
 		writeSSE("plan", string(planData))
 
-		authSub := c.Locals("auth_sub")
-		if authSub != nil {
-			userID := authSub.(string)
-			_, err = findOrCreateUser(userID)
-			if err != nil {
-				writeSSE("warning", fmt.Sprintf(`{"message": "Plan generated but not saved: %s"}`, err.Error()))
-				writeSSE("complete", `{"saved": false}`)
-				return
-			}
+		complete := func(saved bool) {
+			data, _ := json.Marshal(fiber.Map{"saved": saved, "source": result.Source})
+			writeSSE("complete", string(data))
+		}
 
-			id := uuid.NewString()
-			planJson, _ := json.Marshal(tasks)
-			_, err = db.Pool.Exec(context.Background(),
-				"INSERT INTO plans (id, user_id, title, goal, plan_json, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,now(),now())",
-				id, userID, req.Title, req.Goal, planJson,
-			)
+		if result.Source == services.SourceFallback {
+			data, _ := json.Marshal(fiber.Map{"message": fallbackNotSavedMessage, "reason": result.Reason})
+			writeSSE("warning", string(data))
+			complete(false)
+		} else if userID != "" {
+			planID, err = savePlan(context.Background(), userID, req.Title, req.Goal, tasksFromGenerated(result.Tasks))
 			if err != nil {
 				writeSSE("warning", fmt.Sprintf(`{"message": "Plan generated but not saved: %s"}`, err.Error()))
-				writeSSE("complete", `{"saved": false}`)
+				complete(false)
 				return
 			}
 
-			writeSSE("saved", fmt.Sprintf(`{"id": "%s", "message": "Plan saved successfully!"}`, id))
-			writeSSE("complete", `{"saved": true}`)
+			writeSSE("saved", fmt.Sprintf(`{"id": "%s", "message": "Plan saved successfully!"}`, planID))
+			complete(true)
 		} else {
 			writeSSE("info", `{"message": "Plan generated but not saved. Login to save your plans."}`)
-			writeSSE("complete", `{"saved": false}`)
+			complete(false)
 		}
 	})
 
 	return nil
 }
+
+// streamedTask is the payload of a "task" event.
+type streamedTask struct {
+	Index int `json:"index"`
+	services.Task
+}
+
+type updatePlanReq struct {
+	Title string    `json:"title"`
+	Goal  string    `json:"goal"`
+	Plan  []db.Task `json:"plan"`
+}
+
+type patchPlanReq struct {
+	Title *string    `json:"title"`
+	Goal  *string    `json:"goal"`
+	Plan  *[]db.Task `json:"plan"`
+}
+
+func GetPlanHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	plan, err := loadPlan(sub, c.Params("id"))
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(plan)
+}
+
+func UpdatePlanHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req updatePlanReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+	}
+	if req.Goal == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "goal_required"})
+	}
+	if len(req.Plan) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "plan_required"})
+	}
+	if code := prepareTaskInput(req.Plan); code != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": code})
+	}
+
+	ctx := context.Background()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	id := c.Params("id")
+	if status, body := beginPlanChange(ctx, tx, id, sub); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	_, err = tx.Exec(ctx,
+		"UPDATE plans SET title=$1, goal=$2 WHERE id=$3",
+		req.Title, req.Goal, id,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := replaceTasks(ctx, tx, id, req.Plan); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if _, err := recordRevision(ctx, tx, id, revisionMeta{Author: sub, Reason: db.RevisionEdit}); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	return GetPlanHandler(c)
+}
+
+func PatchPlanHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req patchPlanReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+	}
+	if req.Title == nil && req.Goal == nil && req.Plan == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "no_fields_to_update"})
+	}
+	if req.Goal != nil && *req.Goal == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "goal_required"})
+	}
+	if req.Plan != nil && len(*req.Plan) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "plan_required"})
+	}
+	if req.Plan != nil {
+		if code := prepareTaskInput(*req.Plan); code != "" {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": code})
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	// NULL parameters keep the current column value; the update always runs so
+	// updated_at moves even when only the tasks change.
+	id := c.Params("id")
+	if status, body := beginPlanChange(ctx, tx, id, sub); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	_, err = tx.Exec(ctx,
+		"UPDATE plans SET title=COALESCE($1, title), goal=COALESCE($2, goal) WHERE id=$3",
+		req.Title, req.Goal, id,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if req.Plan != nil {
+		if err := replaceTasks(ctx, tx, id, *req.Plan); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+		}
+	}
+	if _, err := recordRevision(ctx, tx, id, revisionMeta{Author: sub, Reason: db.RevisionEdit}); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	return GetPlanHandler(c)
+}
+
+func DeletePlanHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	tag, err := db.Pool.Exec(context.Background(), "DELETE FROM plans WHERE id=$1 AND user_id=$2", c.Params("id"), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "delete_failed", "detail": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func authSubject(c *fiber.Ctx) (string, bool) {
+	sub, ok := c.Locals("auth_sub").(string)
+	return sub, ok && sub != ""
+}
+
+func loadPlan(userID, id string) (map[string]interface{}, error) {
+	var title, goal string
+	var createdAt, updatedAt time.Time
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT title, goal, created_at, updated_at FROM plans WHERE id=$1 AND user_id=$2",
+		id, userID,
+	).Scan(&title, &goal, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := loadTasks(context.Background(), []string{id})
+	if err != nil {
+		return nil, err
+	}
+	planTaskList := planTasks(tasks, id)
+	return map[string]interface{}{
+		"id":              id,
+		"title":           title,
+		"goal":            goal,
+		"plan":            planTaskList,
+		"percentComplete": progressPercent(planTaskList),
+		"createdAt":       createdAt,
+		"updatedAt":       updatedAt,
+	}, nil
+}
+
+func planTasks(tasks map[string][]db.Task, planID string) []db.Task {
+	if t, ok := tasks[planID]; ok {
+		return t
+	}
+	return []db.Task{}
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+func tasksFromGenerated(tasks []services.Task) []db.Task {
+	out := make([]db.Task, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, db.Task{
+			Title:        t.Task,
+			DurationDays: t.DurationDays,
+			DependsOn:    t.DependsOn,
+		})
+	}
+	return out
+}
+
+// generatedFromTasks is the inverse of tasksFromGenerated, used to hand a
+// stored plan back to the generator.
+func generatedFromTasks(tasks []db.Task) []services.Task {
+	out := make([]services.Task, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, services.Task{
+			Task:         t.Title,
+			DurationDays: t.DurationDays,
+			DependsOn:    t.DependsOn,
+		})
+	}
+	return out
+}
+
+func savePlan(ctx context.Context, userID, title, goal string, tasks []db.Task) (string, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	id := uuid.NewString()
+	_, err = tx.Exec(ctx,
+		"INSERT INTO plans (id, user_id, title, goal, created_at, updated_at) VALUES ($1,$2,$3,$4,now(),now())",
+		id, userID, title, goal,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if err := insertTasks(ctx, tx, id, tasks, nil); err != nil {
+		return "", err
+	}
+	if _, err := recordRevision(ctx, tx, id, revisionMeta{Author: userID, Reason: db.RevisionGenerate}); err != nil {
+		return "", err
+	}
+
+	return id, tx.Commit(ctx)
+}
+
+// replaceTasks swaps the task list of a plan. Task IDs supplied by the client
+// are kept only when they already belong to the plan, and kept tasks retain
+// their server-side start and completion timestamps.
+func replaceTasks(ctx context.Context, tx pgx.Tx, planID string, tasks []db.Task) error {
+	rows, err := tx.Query(ctx, "SELECT id, started_at, completed_at FROM tasks WHERE plan_id=$1", planID)
+	if err != nil {
+		return err
+	}
+	existing := map[string]db.Task{}
+	for rows.Next() {
+		var t db.Task
+		if err := rows.Scan(&t.ID, &t.StartedAt, &t.CompletedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[t.ID] = t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(existing))
+	for i := range tasks {
+		prev, ok := existing[tasks[i].ID]
+		tasks[i].StartedAt, tasks[i].CompletedAt = prev.StartedAt, prev.CompletedAt
+		keep[tasks[i].ID] = ok
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM tasks WHERE plan_id=$1", planID); err != nil {
+		return err
+	}
+	if err := insertTasks(ctx, tx, planID, tasks, keep); err != nil {
+		return err
+	}
+	return rollUpDurations(ctx, tx, planID)
+}
+
+// insertTasks stores tasks in order and resolves their name-based
+// dependencies to task IDs within the plan. Unknown names are dropped. Parent
+// references are matched against the IDs tasks carry on input; afterwards
+// each task holds the ID and parent it was stored with.
+func insertTasks(ctx context.Context, tx pgx.Tx, planID string, tasks []db.Task, keepIDs map[string]bool) error {
+	ids := make([]string, len(tasks))
+	byName := make(map[string]string, len(tasks))
+	byOldID := make(map[string]string, len(tasks))
+	used := make(map[string]bool, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+		if !keepIDs[t.ID] || used[t.ID] {
+			ids[i] = uuid.NewString()
+		}
+		used[ids[i]] = true
+		if _, ok := byName[t.Title]; !ok {
+			byName[t.Title] = ids[i]
+		}
+		if _, ok := byOldID[t.ID]; t.ID != "" && !ok {
+			byOldID[t.ID] = ids[i]
+		}
+	}
+
+	now := time.Now()
+	for i, t := range tasks {
+		if t.Status == "" {
+			t.Status = db.TaskStatusTodo
+		}
+		applyStatusTimestamps(&t, now)
+		duration := t.DurationDays
+		if duration < 1 {
+			duration = 1
+		}
+		_, err := tx.Exec(ctx,
+			"INSERT INTO tasks (id, plan_id, position, title, duration_days, status, started_at, completed_at, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,now(),now())",
+			ids[i], planID, i, t.Title, duration, t.Status, t.StartedAt, t.CompletedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range tasks {
+		tasks[i].ID = ids[i]
+	}
+
+	// Parents are linked once every row exists, since a subtask may be listed
+	// before its parent.
+	for i, t := range tasks {
+		if t.ParentID == nil {
+			continue
+		}
+		parentID, ok := byOldID[*t.ParentID]
+		if !ok || parentID == ids[i] {
+			tasks[i].ParentID = nil
+			continue
+		}
+		tasks[i].ParentID = &parentID
+		if _, err := tx.Exec(ctx, "UPDATE tasks SET parent_id=$1 WHERE id=$2", parentID, ids[i]); err != nil {
+			return err
+		}
+	}
+
+	for i, t := range tasks {
+		for _, name := range t.DependsOn {
+			depID, ok := byName[name]
+			if !ok || depID == ids[i] {
+				continue
+			}
+			_, err := tx.Exec(ctx,
+				"INSERT INTO task_dependencies (task_id, depends_on_id) VALUES ($1,$2) ON CONFLICT DO NOTHING",
+				ids[i], depID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// loadTasks returns the tasks of the given plans keyed by plan ID, ordered by position.
+func loadTasks(ctx context.Context, planIDs []string) (map[string][]db.Task, error) {
+	return loadTasksWith(ctx, db.Pool, planIDs)
+}
+
+func loadTasksWith(ctx context.Context, q querier, planIDs []string) (map[string][]db.Task, error) {
+	res := make(map[string][]db.Task, len(planIDs))
+	if len(planIDs) == 0 {
+		return res, nil
+	}
+
+	rows, err := q.Query(ctx,
+		"SELECT id, plan_id, parent_id, position, title, duration_days, status, started_at, completed_at, created_at, updated_at FROM tasks WHERE plan_id = ANY($1) ORDER BY plan_id, position",
+		planIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]*db.Task{}
+	var order []db.Task
+	for rows.Next() {
+		var t db.Task
+		if err := rows.Scan(&t.ID, &t.PlanID, &t.ParentID, &t.Position, &t.Title, &t.DurationDays, &t.Status, &t.StartedAt, &t.CompletedAt, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.DependsOn = []string{}
+		t.DependsOnIDs = []string{}
+		order = append(order, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range order {
+		index[order[i].ID] = &order[i]
+	}
+
+	depRows, err := q.Query(ctx,
+		`SELECT td.task_id, td.depends_on_id, d.title
+		 FROM task_dependencies td
+		 JOIN tasks d ON d.id = td.depends_on_id
+		 WHERE d.plan_id = ANY($1)
+		 ORDER BY d.position`,
+		planIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer depRows.Close()
+	for depRows.Next() {
+		var taskID, depID, depTitle string
+		if err := depRows.Scan(&taskID, &depID, &depTitle); err != nil {
+			return nil, err
+		}
+		if t, ok := index[taskID]; ok {
+			t.DependsOn = append(t.DependsOn, depTitle)
+			t.DependsOnIDs = append(t.DependsOnIDs, depID)
+		}
+	}
+	if err := depRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range order {
+		res[t.PlanID] = append(res[t.PlanID], t)
+	}
+	return res, nil
+}
+
+// applyStatusTimestamps keeps started_at and completed_at consistent with the
+// task status: work that has begun has a start time, and only done tasks have
+// a completion time.
+func applyStatusTimestamps(t *db.Task, now time.Time) {
+	switch t.Status {
+	case db.TaskStatusTodo:
+		t.StartedAt, t.CompletedAt = nil, nil
+	case db.TaskStatusDone:
+		if t.StartedAt == nil {
+			t.StartedAt = &now
+		}
+		if t.CompletedAt == nil {
+			t.CompletedAt = &now
+		}
+	default:
+		if t.StartedAt == nil && t.Status == db.TaskStatusInProgress {
+			t.StartedAt = &now
+		}
+		t.CompletedAt = nil
+	}
+}
+
+// progressPercent is the share of leaf tasks marked done, rounded down. A
+// task that was expanded into subtasks is measured through them.
+func progressPercent(tasks []db.Task) int {
+	children := childIndex(tasks)
+	total, done := 0, 0
+	for i, t := range tasks {
+		if len(children[i]) > 0 {
+			continue
+		}
+		total++
+		if t.Status == db.TaskStatusDone {
+			done++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return done * 100 / total
+}
+
+// prepareTaskInput validates client-supplied tasks and rewrites their
+// dependencies to the exact names of the tasks they resolve to. It returns an
+// error code for the first problem found, or an empty string when the list
+// can be stored.
+func prepareTaskInput(tasks []db.Task) string {
+	nodes := make([]graph.Node, len(tasks))
+	for i, t := range tasks {
+		if t.Title == "" {
+			return "task_title_required"
+		}
+		if t.Status != "" && !db.IsValidTaskStatus(t.Status) {
+			return "invalid_task_status"
+		}
+		nodes[i] = graph.Node{Name: t.Title, DependsOn: t.DependsOn}
+	}
+
+	if !validParents(tasks) {
+		return "invalid_parent"
+	}
+
+	g := graph.Resolve(nodes)
+	for _, issue := range g.BlockingIssues() {
+		switch issue.Kind {
+		case graph.IssueCycle:
+			return "dependency_cycle"
+		default:
+			return "invalid_dependency"
+		}
+	}
+
+	for i, n := range g.Repair() {
+		tasks[i].DependsOn = n.DependsOn
+	}
+	return ""
+}
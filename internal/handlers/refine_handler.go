@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// maxInstructionLength bounds refinement instructions.
+const maxInstructionLength = 1000
+
+type refineReq struct {
+	Instruction string `json:"instruction"`
+}
+
+// RefinePlanHandler asks the generator to revise a saved plan according to a
+// natural-language instruction, stores the result as the plan's new task list
+// and revision, and returns what changed.
+func RefinePlanHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req refineReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+	}
+	req.Instruction = strings.TrimSpace(req.Instruction)
+	if req.Instruction == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "instruction_required"})
+	}
+	if len(req.Instruction) > maxInstructionLength {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "instruction_too_long"})
+	}
+
+	planID := c.Params("id")
+	var goal string
+	var updatedAt time.Time
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT COALESCE(goal, ''), updated_at FROM plans WHERE id=$1 AND user_id=$2",
+		planID, sub,
+	).Scan(&goal, &updatedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	tasks, err := loadTasks(context.Background(), []string{planID})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	current := planTasks(tasks, planID)
+	existing := generatedFromTasks(current)
+
+	generator := c.Locals("generator").(services.PlanGenerator)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	result, err := generator.GeneratePlan(ctx, services.PlanRequest{
+		Goal:        goal,
+		Existing:    existing,
+		Instruction: req.Instruction,
+	})
+	if err != nil {
+		result = failedGeneration(generator, err)
+	}
+	recordGeneration(sub, planID, generationRefine, result)
+	if status, body := generationError(err); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	if result.Source == services.SourceFallback {
+		// A template plan is not a revision of anything.
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "refinement_failed", "reason": result.Reason})
+	}
+
+	diff := services.DiffTasks(existing, result.Tasks)
+	refined := tasksFromGenerated(result.Tasks)
+	for i, j := range services.MatchTasks(existing, result.Tasks) {
+		if j >= 0 {
+			refined[i].ID = current[j].ID
+			refined[i].ParentID = current[j].ParentID
+			refined[i].Status = current[j].Status
+		}
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(context.Background())
+
+	// The plan may have been edited while the generator was running; applying
+	// the refinement would silently discard that edit.
+	var lockedAt time.Time
+	err = tx.QueryRow(context.Background(), "SELECT updated_at FROM plans WHERE id=$1 FOR UPDATE", planID).Scan(&lockedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if !lockedAt.Equal(updatedAt) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "plan_modified"})
+	}
+
+	if err := ensureBaseRevision(context.Background(), tx, planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	if err := replaceTasks(context.Background(), tx, planID, refined); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if _, err := tx.Exec(context.Background(), "UPDATE plans SET updated_at=now() WHERE id=$1", planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	revision, err := recordRevision(context.Background(), tx, planID, revisionMeta{
+		Author:      sub,
+		Reason:      db.RevisionRefine,
+		Instruction: req.Instruction,
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	plan, err := loadPlan(sub, planID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"plan":     plan,
+		"diff":     diff,
+		"revision": revision,
+		"source":   result.Source,
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+func ListRevisionsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	revisions, err := listRevisions(context.Background(), c.Params("id"), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	if revisions == nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+	return c.JSON(fiber.Map{"revisions": revisions})
+}
+
+func GetRevisionHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	rev, err := c.ParamsInt("rev")
+	if err != nil || rev < 1 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_revision"})
+	}
+	revision, err := loadRevision(context.Background(), c.Params("id"), sub, rev)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "revision_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(revision)
+}
+
+// DiffRevisionsHandler compares the task lists of two revisions of a plan.
+// Tasks are paired by ID first, so a rename shows up as a change.
+func DiffRevisionsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	from, to := c.QueryInt("from"), c.QueryInt("to")
+	if from < 1 || to < 1 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_revision"})
+	}
+
+	planID := c.Params("id")
+	revisions := make([]*db.PlanRevision, 2)
+	for i, rev := range []int{from, to} {
+		r, err := loadRevision(context.Background(), planID, sub, rev)
+		if err == pgx.ErrNoRows {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "revision_not_found", "revision": rev})
+		}
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+		}
+		revisions[i] = r
+	}
+
+	before, after := revisions[0].Tasks, revisions[1].Tasks
+	return c.JSON(fiber.Map{
+		"planId": planID,
+		"from":   from,
+		"to":     to,
+		"diff":   services.DiffMatchedTasks(generatedFromRevision(before), generatedFromRevision(after), matchRevisionTasks(before, after)),
+	})
+}
+
+// RestoreRevisionHandler makes an earlier revision the plan's current state.
+// The restore is itself recorded as a new revision; history is never
+// rewritten.
+func RestoreRevisionHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	rev, err := c.ParamsInt("rev")
+	if err != nil || rev < 1 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_revision"})
+	}
+
+	ctx := context.Background()
+	planID := c.Params("id")
+	target, err := loadRevision(ctx, planID, sub, rev)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "revision_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	if status, body := beginPlanChange(ctx, tx, planID, sub); body != nil {
+		return c.Status(status).JSON(body)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE plans SET title=$1, goal=$2 WHERE id=$3", target.Title, target.Goal, planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	tasks := make([]db.Task, len(target.Tasks))
+	for i, t := range target.Tasks {
+		tasks[i] = db.Task{ID: t.ID, ParentID: t.ParentID, Title: t.Title, DurationDays: t.DurationDays, DependsOn: t.DependsOn, Status: t.Status}
+	}
+	if err := replaceTasks(ctx, tx, planID, tasks); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	revision, err := recordRevision(ctx, tx, planID, revisionMeta{Author: sub, Reason: db.RevisionRestore, RestoredFrom: &rev})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	plan, err := loadPlan(sub, planID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(fiber.Map{"plan": plan, "revision": revision, "restoredFrom": rev})
+}
+
+func generatedFromRevision(tasks []db.RevisionTask) []services.Task {
+	out := make([]services.Task, len(tasks))
+	for i, t := range tasks {
+		out[i] = services.Task{Task: t.Title, DurationDays: t.DurationDays, DependsOn: t.DependsOn}
+	}
+	return out
+}
+
+// matchRevisionTasks pairs tasks by ID and falls back to names for tasks
+// whose ID is unknown to the other revision.
+func matchRevisionTasks(before, after []db.RevisionTask) []int {
+	match := services.MatchTasks(generatedFromRevision(before), generatedFromRevision(after))
+
+	byID := make(map[string]int, len(before))
+	for j, t := range before {
+		if t.ID != "" {
+			byID[t.ID] = j
+		}
+	}
+	claimed := make(map[int]bool, len(after))
+	for i, t := range after {
+		if j, ok := byID[t.ID]; ok && t.ID != "" {
+			match[i] = j
+			claimed[j] = true
+		}
+	}
+	for i, t := range after {
+		if _, ok := byID[t.ID]; ok && t.ID != "" {
+			continue
+		}
+		if claimed[match[i]] {
+			match[i] = -1
+		}
+	}
+	return match
+}
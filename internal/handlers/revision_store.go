@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+type revisionMeta struct {
+	Author       string
+	Reason       string
+	Instruction  string
+	RestoredFrom *int
+}
+
+// lockPlan takes the row lock of a plan owned by userID for the rest of tx.
+// Holding it serialises changes to the plan and the numbering of its
+// revisions. It returns pgx.ErrNoRows when the plan does not exist.
+func lockPlan(ctx context.Context, tx pgx.Tx, planID, userID string) error {
+	var id string
+	return tx.QueryRow(ctx, "SELECT id FROM plans WHERE id=$1 AND user_id=$2 FOR UPDATE", planID, userID).Scan(&id)
+}
+
+// beginPlanChange locks a plan and makes sure its current state is recorded
+// before tx modifies it. It returns a nil body on success.
+func beginPlanChange(ctx context.Context, tx pgx.Tx, planID, userID string) (int, fiber.Map) {
+	err := lockPlan(ctx, tx, planID, userID)
+	if err == pgx.ErrNoRows {
+		return http.StatusNotFound, fiber.Map{"error": "plan_not_found"}
+	}
+	if err == nil {
+		err = ensureBaseRevision(ctx, tx, planID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fiber.Map{"error": "update_failed", "detail": err.Error()}
+	}
+	return http.StatusOK, nil
+}
+
+// ensureBaseRevision snapshots a plan that predates revision history as
+// revision 1, so that the change about to be made can be diffed and undone.
+func ensureBaseRevision(ctx context.Context, tx pgx.Tx, planID string) error {
+	var exists bool
+	err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM plan_revisions WHERE plan_id=$1)", planID).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	_, err = recordRevision(ctx, tx, planID, revisionMeta{Reason: db.RevisionInitial})
+	return err
+}
+
+// recordRevision stores the current state of a plan, as seen by tx, as its
+// next revision and returns the revision number.
+func recordRevision(ctx context.Context, tx pgx.Tx, planID string, meta revisionMeta) (int, error) {
+	tasks, err := loadTasksWith(ctx, tx, []string{planID})
+	if err != nil {
+		return 0, err
+	}
+	snapshot, err := json.Marshal(revisionTasks(planTasks(tasks, planID)))
+	if err != nil {
+		return 0, err
+	}
+
+	var revision int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO plan_revisions (plan_id, revision, title, goal, tasks, author, reason, instruction, restored_from, created_at)
+		 SELECT p.id, COALESCE((SELECT MAX(revision) FROM plan_revisions WHERE plan_id=p.id), 0) + 1,
+		        p.title, p.goal, $2::jsonb, NULLIF($3, ''), $4, NULLIF($5, ''), $6::integer, now()
+		 FROM plans p WHERE p.id=$1
+		 RETURNING revision`,
+		planID, snapshot, meta.Author, meta.Reason, meta.Instruction, meta.RestoredFrom,
+	).Scan(&revision)
+	return revision, err
+}
+
+func revisionTasks(tasks []db.Task) []db.RevisionTask {
+	out := make([]db.RevisionTask, len(tasks))
+	for i, t := range tasks {
+		out[i] = db.RevisionTask{
+			ID:           t.ID,
+			ParentID:     t.ParentID,
+			Title:        t.Title,
+			DurationDays: t.DurationDays,
+			DependsOn:    t.DependsOn,
+			Status:       t.Status,
+		}
+	}
+	return out
+}
+
+// listRevisions returns the revisions of a plan owned by userID, newest
+// first, without their task snapshots. A nil slice means the plan does not
+// exist.
+func listRevisions(ctx context.Context, planID, userID string) ([]db.PlanRevision, error) {
+	var exists bool
+	err := db.Pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM plans WHERE id=$1 AND user_id=$2)", planID, userID).Scan(&exists)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT revision, COALESCE(title, ''), COALESCE(goal, ''), COALESCE(author, ''), reason, COALESCE(instruction, ''), restored_from, created_at
+		 FROM plan_revisions WHERE plan_id=$1 ORDER BY revision DESC`,
+		planID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []db.PlanRevision{}
+	for rows.Next() {
+		r := db.PlanRevision{PlanID: planID}
+		if err := rows.Scan(&r.Revision, &r.Title, &r.Goal, &r.Author, &r.Reason, &r.Instruction, &r.RestoredFrom, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// loadRevision returns one revision of a plan owned by userID, or
+// pgx.ErrNoRows.
+func loadRevision(ctx context.Context, planID, userID string, revision int) (*db.PlanRevision, error) {
+	r := &db.PlanRevision{PlanID: planID, Revision: revision}
+	var snapshot []byte
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(r.title, ''), COALESCE(r.goal, ''), r.tasks, COALESCE(r.author, ''), r.reason, COALESCE(r.instruction, ''), r.restored_from, r.created_at
+		 FROM plan_revisions r JOIN plans p ON p.id = r.plan_id
+		 WHERE r.plan_id=$1 AND r.revision=$2 AND p.user_id=$3`,
+		planID, revision, userID,
+	).Scan(&r.Title, &r.Goal, &snapshot, &r.Author, &r.Reason, &r.Instruction, &r.RestoredFrom, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snapshot, &r.Tasks); err != nil {
+		return nil, err
+	}
+	for i := range r.Tasks {
+		if r.Tasks[i].DependsOn == nil {
+			r.Tasks[i].DependsOn = []string{}
+		}
+	}
+	return r, nil
+}
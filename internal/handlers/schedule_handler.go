@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/schedule"
+)
+
+type scheduledTask struct {
+	ID             string   `json:"id"`
+	ParentID       *string  `json:"parent_id"`
+	Task           string   `json:"task"`
+	DurationDays   int      `json:"duration_days"`
+	DependsOnIDs   []string `json:"depends_on_ids"`
+	Status         string   `json:"status"`
+	EarliestStart  int      `json:"earliest_start"`
+	EarliestFinish int      `json:"earliest_finish"`
+	LatestStart    int      `json:"latest_start"`
+	LatestFinish   int      `json:"latest_finish"`
+	Slack          int      `json:"slack"`
+	Critical       bool     `json:"critical"`
+	StartDate      string   `json:"start_date"`
+	EndDate        string   `json:"end_date"`
+}
+
+func ScheduleHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	planID := c.Params("id")
+	var exists bool
+	err := db.Pool.QueryRow(context.Background(), "SELECT true FROM plans WHERE id=$1 AND user_id=$2", planID, sub).Scan(&exists)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "plan_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	cal, err := loadCalendar(context.Background(), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "settings_load_failed", "detail": err.Error()})
+	}
+
+	start := cal.Today()
+	if s := c.Query("start"); s != "" {
+		parsed, err := time.ParseInLocation(calendar.DateLayout, s, cal.Location)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_start_date"})
+		}
+		start = parsed
+	}
+
+	tasks, err := loadTasks(context.Background(), []string{planID})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	planTaskList := planTasks(tasks, planID)
+
+	g, durations, leaves := taskGraph(planTaskList)
+	sched, err := schedule.Compute(g, durations, start, cal)
+	if err == graph.ErrCycle {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "dependency_cycle"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "schedule_failed", "detail": err.Error()})
+	}
+
+	return c.JSON(scheduleResponse(planID, planTaskList, leaves, sched))
+}
+
+// taskGraph builds a dependency graph over stored tasks using their IDs,
+// which stay unambiguous even when titles repeat. Only tasks without subtasks
+// are scheduled: a dependency on an expanded task waits for all of its
+// subtasks, and a dependency of an expanded task holds back all of them. The
+// returned leaves map graph nodes back to task indices.
+func taskGraph(tasks []db.Task) (g *graph.Graph, durations []int, leaves []int) {
+	index := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+	}
+	children := childIndex(tasks)
+	parent := make([]int, len(tasks))
+	for i := range parent {
+		parent[i] = -1
+	}
+	for p, cs := range children {
+		for _, c := range cs {
+			parent[c] = p
+		}
+	}
+
+	node := make(map[int]int, len(tasks))
+	for i := range tasks {
+		if len(children[i]) == 0 {
+			node[i] = len(leaves)
+			leaves = append(leaves, i)
+		}
+	}
+
+	g = &graph.Graph{
+		Nodes: make([]graph.Node, len(leaves)),
+		Deps:  make([][]int, len(leaves)),
+	}
+	durations = make([]int, len(leaves))
+	for n, i := range leaves {
+		t := tasks[i]
+		g.Nodes[n] = graph.Node{Name: t.Title, DependsOn: t.DependsOn}
+		durations[n] = t.DurationDays
+
+		seen := map[int]bool{}
+		visited := map[int]bool{}
+		for k := i; k >= 0 && !visited[k]; k = parent[k] {
+			visited[k] = true
+			for _, depID := range tasks[k].DependsOnIDs {
+				j, ok := index[depID]
+				if !ok {
+					continue
+				}
+				for _, leaf := range leafDescendants(children, j) {
+					if m := node[leaf]; !seen[m] {
+						seen[m] = true
+						g.Deps[n] = append(g.Deps[n], m)
+					}
+				}
+			}
+		}
+	}
+	return g, durations, leaves
+}
+
+func scheduleResponse(planID string, tasks []db.Task, leaves []int, sched *schedule.Schedule) fiber.Map {
+	entries := taskEntries(tasks, leaves, sched)
+	out := make([]scheduledTask, len(tasks))
+	for i, t := range tasks {
+		e := entries[i]
+		out[i] = scheduledTask{
+			ID:             t.ID,
+			ParentID:       t.ParentID,
+			Task:           t.Title,
+			DurationDays:   t.DurationDays,
+			DependsOnIDs:   t.DependsOnIDs,
+			Status:         t.Status,
+			EarliestStart:  e.EarliestStart,
+			EarliestFinish: e.EarliestFinish,
+			LatestStart:    e.LatestStart,
+			LatestFinish:   e.LatestFinish,
+			Slack:          e.Slack,
+			Critical:       e.Critical,
+			StartDate:      e.StartDate.Format(calendar.DateLayout),
+			EndDate:        e.EndDate.Format(calendar.DateLayout),
+		}
+	}
+
+	critical := make([]string, len(sched.CriticalPath))
+	for k, n := range sched.CriticalPath {
+		critical[k] = tasks[leaves[n]].ID
+	}
+
+	return fiber.Map{
+		"planId":       planID,
+		"start":        sched.Start.Format(calendar.DateLayout),
+		"end":          sched.End.Format(calendar.DateLayout),
+		"durationDays": sched.DurationDays,
+		"criticalPath": critical,
+		"tasks":        out,
+	}
+}
+
+// taskEntries expands the leaf schedule to every task. An expanded task spans
+// its subtasks and is critical when any of them is.
+func taskEntries(tasks []db.Task, leaves []int, sched *schedule.Schedule) []schedule.Entry {
+	entries := make([]schedule.Entry, len(tasks))
+	for n, i := range leaves {
+		entries[i] = sched.Entries[n]
+	}
+
+	children := childIndex(tasks)
+	leafNode := make(map[int]int, len(leaves))
+	for n, i := range leaves {
+		leafNode[i] = n
+	}
+	for i := range tasks {
+		if len(children[i]) == 0 {
+			continue
+		}
+		var e schedule.Entry
+		for k, leaf := range leafDescendants(children, i) {
+			le := sched.Entries[leafNode[leaf]]
+			if k == 0 {
+				e = le
+				continue
+			}
+			e.EarliestStart = min(e.EarliestStart, le.EarliestStart)
+			e.EarliestFinish = max(e.EarliestFinish, le.EarliestFinish)
+			e.LatestStart = min(e.LatestStart, le.LatestStart)
+			e.LatestFinish = max(e.LatestFinish, le.LatestFinish)
+			e.Slack = min(e.Slack, le.Slack)
+			e.Critical = e.Critical || le.Critical
+			if le.StartDate.Before(e.StartDate) {
+				e.StartDate = le.StartDate
+			}
+			if le.EndDate.After(e.EndDate) {
+				e.EndDate = le.EndDate
+			}
+		}
+		entries[i] = e
+	}
+	return entries
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// startSession finishes a login in session mode: the tokens stay on the
+// server and the browser gets the user and an HttpOnly session cookie.
+func startSession(c *fiber.Ctx, cfg *config.Config, userID, issuer string, token *services.TokenResponse, user fiber.Map) error {
+	sessions := c.Locals("sessions").(*services.SessionStore)
+	sess, cookie, err := sessions.Create(context.Background(), userID, issuer, token, c.Get("User-Agent"), c.IP())
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "session_creation_failed", "detail": err.Error()})
+	}
+	middleware.SetSessionCookie(c, cfg, cookie, sess.ExpiresAt)
+	return c.JSON(fiber.Map{
+		"user":    user,
+		"session": sess,
+	})
+}
+
+// ListSessionsHandler lists the caller's active sessions and marks the one
+// the request came with.
+func ListSessionsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+	currentID, _ := c.Locals("session_id").(string)
+
+	sessions, err := c.Locals("sessions").(*services.SessionStore).List(context.Background(), sub, currentID)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(fiber.Map{"sessions": sessions})
+}
+
+// RevokeSessionHandler ends one of the caller's sessions, for instance one
+// left signed in on another device.
+func RevokeSessionHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	id := c.Params("id")
+	found, err := c.Locals("sessions").(*services.SessionStore).Revoke(context.Background(), sub, id)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "revoke_failed", "detail": err.Error()})
+	}
+	if !found {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "session_not_found"})
+	}
+	if currentID, _ := c.Locals("session_id").(string); currentID == id {
+		middleware.ClearSessionCookie(c, c.Locals("config").(*config.Config))
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// RevokeSessionsHandler ends all of the caller's sessions, including the
+// current one.
+func RevokeSessionsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	n, err := c.Locals("sessions").(*services.SessionStore).RevokeAll(context.Background(), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "revoke_failed", "detail": err.Error()})
+	}
+	if _, ok := c.Locals("session_id").(string); ok {
+		middleware.ClearSessionCookie(c, c.Locals("config").(*config.Config))
+	}
+	return c.JSON(fiber.Map{"revoked": n})
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+type userSettings struct {
+	Timezone    string             `json:"timezone"`
+	WorkingDays []string           `json:"working_days"`
+	Holidays    []calendar.Holiday `json:"holidays"`
+}
+
+type updateSettingsReq struct {
+	Timezone    string   `json:"timezone"`
+	WorkingDays []string `json:"working_days"`
+}
+
+func GetSettingsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	settings, err := loadUserSettings(context.Background(), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(settings)
+}
+
+func UpdateSettingsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req updateSettingsReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	cal, err := calendar.New(req.Timezone, req.WorkingDays, nil)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_settings", "detail": err.Error()})
+	}
+
+	userID, err := findOrCreateUser(sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO user_settings (user_id, timezone, working_days, created_at, updated_at) VALUES ($1,$2,$3,now(),now())
+		 ON CONFLICT (user_id) DO UPDATE SET timezone=EXCLUDED.timezone, working_days=EXCLUDED.working_days`,
+		userID, cal.Location.String(), cal.WorkingDayNames(),
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	return GetSettingsHandler(c)
+}
+
+// UploadHolidaysHandler replaces the user's holiday list with the dates from
+// an ICS or JSON document, sent either as the raw body or as a multipart
+// "file" field.
+func UploadHolidaysHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	data, isICS, err := holidayUpload(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_upload", "detail": err.Error()})
+	}
+
+	var holidays []calendar.Holiday
+	if isICS {
+		holidays, err = calendar.ParseICS(bytes.NewReader(data))
+	} else {
+		holidays, err = calendar.ParseJSON(data)
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_holidays", "detail": err.Error()})
+	}
+
+	userID, err := findOrCreateUser(sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
+	}
+
+	ctx := context.Background()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM user_holidays WHERE user_id=$1", userID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	for _, h := range holidays {
+		date, _ := time.Parse(calendar.DateLayout, h.Date)
+		if _, err := tx.Exec(ctx, "INSERT INTO user_holidays (user_id, date, name) VALUES ($1,$2,$3)", userID, date, h.Name); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	return GetSettingsHandler(c)
+}
+
+func holidayUpload(c *fiber.Ctx) ([]byte, bool, error) {
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > calendar.MaxUploadBytes {
+			return nil, false, fiber.NewError(http.StatusRequestEntityTooLarge, "file too large")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, false, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, calendar.MaxUploadBytes))
+		if err != nil {
+			return nil, false, err
+		}
+		isICS := strings.EqualFold(filepath.Ext(file.Filename), ".ics") ||
+			strings.HasPrefix(file.Header.Get("Content-Type"), "text/calendar")
+		return data, isICS, nil
+	}
+
+	body := c.Body()
+	if len(body) > calendar.MaxUploadBytes {
+		return nil, false, fiber.NewError(http.StatusRequestEntityTooLarge, "body too large")
+	}
+	isICS := strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/calendar") ||
+		bytes.HasPrefix(bytes.TrimSpace(body), []byte("BEGIN:VCALENDAR"))
+	return body, isICS, nil
+}
+
+func loadUserSettings(ctx context.Context, userID string) (*userSettings, error) {
+	settings := &userSettings{
+		Timezone:    "UTC",
+		WorkingDays: calendar.Default().WorkingDayNames(),
+		Holidays:    []calendar.Holiday{},
+	}
+
+	err := db.Pool.QueryRow(ctx,
+		"SELECT timezone, working_days FROM user_settings WHERE user_id=$1", userID,
+	).Scan(&settings.Timezone, &settings.WorkingDays)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, "SELECT date, name FROM user_holidays WHERE user_id=$1 ORDER BY date", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date time.Time
+		var name string
+		if err := rows.Scan(&date, &name); err != nil {
+			return nil, err
+		}
+		settings.Holidays = append(settings.Holidays, calendar.Holiday{Date: date.Format(calendar.DateLayout), Name: name})
+	}
+	return settings, rows.Err()
+}
+
+// loadCalendar returns the working calendar used to schedule the user's plans.
+func loadCalendar(ctx context.Context, userID string) (*calendar.Calendar, error) {
+	settings, err := loadUserSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return calendar.New(settings.Timezone, settings.WorkingDays, settings.Holidays)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+type patchTaskReq struct {
+	Status       *string `json:"status"`
+	Task         *string `json:"task"`
+	DurationDays *int    `json:"duration_days"`
+}
+
+func PatchTaskHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	var req patchTaskReq
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_body"})
+	}
+	if req.Status == nil && req.Task == nil && req.DurationDays == nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "no_fields_to_update"})
+	}
+	if req.Status != nil && !db.IsValidTaskStatus(*req.Status) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_task_status"})
+	}
+	if req.Task != nil && *req.Task == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "task_title_required"})
+	}
+	if req.DurationDays != nil && *req.DurationDays < 1 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_duration"})
+	}
+
+	ctx := context.Background()
+	planID, taskID := c.Params("id"), c.Params("taskId")
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockPlan(ctx, tx, planID, sub); err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "task_not_found"})
+	} else if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	if err := ensureBaseRevision(ctx, tx, planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	var task db.Task
+	err = tx.QueryRow(ctx,
+		`SELECT t.title, t.duration_days, t.status, t.started_at, t.completed_at
+		 FROM tasks t JOIN plans p ON p.id = t.plan_id
+		 WHERE t.id=$1 AND t.plan_id=$2 AND p.user_id=$3
+		 FOR UPDATE OF t`,
+		taskID, planID, sub,
+	).Scan(&task.Title, &task.DurationDays, &task.Status, &task.StartedAt, &task.CompletedAt)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "task_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+
+	if req.DurationDays != nil {
+		var expanded bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id=$1)", taskID).Scan(&expanded); err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+		}
+		if expanded {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "duration_is_rolled_up"})
+		}
+	}
+
+	if req.Status != nil {
+		task.Status = *req.Status
+	}
+	if req.Task != nil {
+		task.Title = *req.Task
+	}
+	if req.DurationDays != nil {
+		task.DurationDays = *req.DurationDays
+	}
+	applyStatusTimestamps(&task, time.Now())
+
+	_, err = tx.Exec(ctx,
+		"UPDATE tasks SET title=$1, duration_days=$2, status=$3, started_at=$4, completed_at=$5 WHERE id=$6",
+		task.Title, task.DurationDays, task.Status, task.StartedAt, task.CompletedAt, taskID,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := rollUpDurations(ctx, tx, planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if _, err := tx.Exec(ctx, "UPDATE plans SET updated_at=now() WHERE id=$1", planID); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if _, err := recordRevision(ctx, tx, planID, revisionMeta{Author: sub, Reason: db.RevisionTaskUpdate}); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+
+	tasks, err := loadTasks(ctx, []string{planID})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	planTaskList := planTasks(tasks, planID)
+	for _, t := range planTaskList {
+		if t.ID == taskID {
+			return c.JSON(fiber.Map{
+				"task":            t,
+				"percentComplete": progressPercent(planTaskList),
+			})
+		}
+	}
+	return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "task_not_found"})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+// childIndex returns, for each task, the indices of its direct subtasks.
+// Parent references that do not resolve within the list are ignored.
+func childIndex(tasks []db.Task) [][]int {
+	index := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+	}
+	children := make([][]int, len(tasks))
+	for i, t := range tasks {
+		if t.ParentID == nil {
+			continue
+		}
+		if p, ok := index[*t.ParentID]; ok && p != i {
+			children[p] = append(children[p], i)
+		}
+	}
+	return children
+}
+
+// validParents reports whether every parent reference names another task in
+// the list and no task is its own ancestor.
+func validParents(tasks []db.Task) bool {
+	parent := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		if t.ParentID != nil {
+			parent[t.ID] = *t.ParentID
+		}
+	}
+	ids := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		ids[t.ID] = true
+	}
+
+	for _, t := range tasks {
+		if t.ParentID == nil {
+			continue
+		}
+		if t.ID == "" || !ids[*t.ParentID] {
+			return false
+		}
+		seen := map[string]bool{t.ID: true}
+		for id, ok := parent[t.ID]; ok; id, ok = parent[id] {
+			if seen[id] {
+				return false
+			}
+			seen[id] = true
+		}
+	}
+	return true
+}
+
+// leafDescendants returns the indices of the tasks without subtasks below
+// task i, or i itself when it has none.
+func leafDescendants(children [][]int, i int) []int {
+	var leaves []int
+	seen := map[int]bool{}
+	var walk func(int)
+	walk = func(k int) {
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		if len(children[k]) == 0 {
+			leaves = append(leaves, k)
+			return
+		}
+		for _, c := range children[k] {
+			walk(c)
+		}
+	}
+	walk(i)
+	return leaves
+}
+
+// rolledUpDurations returns the duration of every task, where a task with
+// subtasks lasts as long as the longest dependency chain among its direct
+// subtasks. Dependencies that leave the sibling group are constraints on the
+// parent and do not count towards it.
+func rolledUpDurations(tasks []db.Task) []int {
+	children := childIndex(tasks)
+	index := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID] = i
+	}
+
+	durations := make([]int, len(tasks))
+	state := make([]int, len(tasks)) // 0 unvisited, 1 in progress, 2 done
+	var resolve func(int) int
+	resolve = func(i int) int {
+		if state[i] == 2 {
+			return durations[i]
+		}
+		durations[i] = max(tasks[i].DurationDays, 1)
+		if state[i] == 1 || len(children[i]) == 0 {
+			state[i] = 2
+			return durations[i]
+		}
+		state[i] = 1
+
+		siblings := make(map[int]bool, len(children[i]))
+		for _, c := range children[i] {
+			siblings[c] = true
+		}
+		// Longest path over the sibling dependency graph; finish[c] is the
+		// earliest finish of subtask c relative to the parent's start.
+		finish := map[int]int{}
+		var visiting map[int]bool
+		var finishOf func(int) int
+		finishOf = func(c int) int {
+			if f, ok := finish[c]; ok {
+				return f
+			}
+			if visiting[c] {
+				return 0
+			}
+			visiting[c] = true
+			start := 0
+			for _, depID := range tasks[c].DependsOnIDs {
+				if d, ok := index[depID]; ok && siblings[d] {
+					start = max(start, finishOf(d))
+				}
+			}
+			visiting[c] = false
+			finish[c] = start + resolve(c)
+			return finish[c]
+		}
+
+		total := 0
+		for _, c := range children[i] {
+			visiting = map[int]bool{}
+			total = max(total, finishOf(c))
+		}
+		durations[i] = max(total, 1)
+		state[i] = 2
+		return durations[i]
+	}
+
+	for i := range tasks {
+		resolve(i)
+	}
+	return durations
+}
+
+// rollUpDurations stores the rolled-up duration of every task with subtasks
+// in the plan.
+func rollUpDurations(ctx context.Context, tx pgx.Tx, planID string) error {
+	loaded, err := loadTasksWith(ctx, tx, []string{planID})
+	if err != nil {
+		return err
+	}
+	tasks := planTasks(loaded, planID)
+	for i, d := range rolledUpDurations(tasks) {
+		if d == tasks[i].DurationDays {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "UPDATE tasks SET duration_days=$1 WHERE id=$2", d, tasks[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+// treeTask builds a task with an optional parent ("" for none) and the IDs
+// of the tasks it depends on.
+func treeTask(id, parent string, days int, deps ...string) db.Task {
+	t := db.Task{ID: id, DurationDays: days, DependsOnIDs: deps}
+	if parent != "" {
+		t.ParentID = &parent
+	}
+	return t
+}
+
+func TestRolledUpDurations(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []db.Task
+		want  []int
+	}{
+		{
+			name:  "no subtasks",
+			tasks: []db.Task{treeTask("a", "", 3), treeTask("b", "", 0, "a")},
+			want:  []int{3, 1},
+		},
+		{
+			name:  "parallel subtasks",
+			tasks: []db.Task{treeTask("p", "", 10), treeTask("c1", "p", 3), treeTask("c2", "p", 5)},
+			want:  []int{5, 3, 5},
+		},
+		{
+			name:  "chained subtasks",
+			tasks: []db.Task{treeTask("p", "", 1), treeTask("c1", "p", 3), treeTask("c2", "p", 4, "c1"), treeTask("c3", "p", 2, "c1")},
+			want:  []int{7, 3, 4, 2},
+		},
+		{
+			// root: x (x1 then x2, 5 days) then y (4 days).
+			name: "nested subtasks",
+			tasks: []db.Task{
+				treeTask("root", "", 1),
+				treeTask("x", "root", 20),
+				treeTask("x1", "x", 2),
+				treeTask("x2", "x", 3, "x1"),
+				treeTask("y", "root", 4, "x"),
+			},
+			want: []int{9, 5, 2, 3, 4},
+		},
+		{
+			name: "dependency outside the siblings",
+			tasks: []db.Task{
+				treeTask("before", "", 6),
+				treeTask("p", "", 1, "before"),
+				treeTask("c1", "p", 2, "before"),
+				treeTask("c2", "p", 3, "c1"),
+			},
+			want: []int{6, 5, 2, 3},
+		},
+		{
+			name:  "sibling cycle",
+			tasks: []db.Task{treeTask("p", "", 1), treeTask("a", "p", 3, "b"), treeTask("b", "p", 2, "a")},
+			want:  []int{5, 3, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rolledUpDurations(tt.tasks); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("rolledUpDurations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
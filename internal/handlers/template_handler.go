@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/validation"
+)
+
+type templateReq struct {
+	Name                string                      `json:"name" validate:"required,max=100"`
+	Description         string                      `json:"description" validate:"max=1000"`
+	Prompt              string                      `json:"prompt" validate:"required"`
+	Variables           []services.TemplateVariable `json:"variables" validate:"max=20,dive"`
+	DefaultDurationDays int                         `json:"default_duration_days"`
+	SeedTasks           []services.Task             `json:"seed_tasks" validate:"max=50"`
+}
+
+const templateColumns = "id, user_id, name, description, prompt, variables, default_duration_days, seed_tasks, created_at, updated_at"
+
+func ListTemplatesHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		"SELECT "+templateColumns+" FROM templates WHERE user_id IS NULL OR user_id=$1 ORDER BY user_id NULLS FIRST, name",
+		sub,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	defer rows.Close()
+
+	templates := []db.Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+		}
+		templates = append(templates, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(fiber.Map{"templates": templates})
+}
+
+func GetTemplateHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	t, err := loadTemplate(context.Background(), c.Params("id"), sub)
+	if err == pgx.ErrNoRows {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "template_not_found"})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(t)
+}
+
+func CreateTemplateHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	req, body := parseTemplateReq(c)
+	if body != nil {
+		return c.Status(http.StatusBadRequest).JSON(body)
+	}
+	userID, err := findOrCreateUser(sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "user_upsert_failed", "detail": err.Error()})
+	}
+
+	variables, seeds := marshalTemplateLists(req)
+	id := uuid.NewString()
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO templates (id, user_id, name, description, prompt, variables, default_duration_days, seed_tasks, created_at, updated_at)
+		 VALUES ($1,$2,$3,$4,$5,$6,$7,$8,now(),now())`,
+		id, userID, req.Name, req.Description, req.Prompt, variables, req.DefaultDurationDays, seeds,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "save_failed", "detail": err.Error()})
+	}
+
+	t, err := loadTemplate(context.Background(), id, sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.Status(http.StatusCreated).JSON(t)
+}
+
+// UpdateTemplateHandler replaces a template owned by the user. Built-in
+// templates cannot be changed.
+func UpdateTemplateHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	req, body := parseTemplateReq(c)
+	if body != nil {
+		return c.Status(http.StatusBadRequest).JSON(body)
+	}
+
+	variables, seeds := marshalTemplateLists(req)
+	tag, err := db.Pool.Exec(context.Background(),
+		`UPDATE templates SET name=$1, description=$2, prompt=$3, variables=$4, default_duration_days=$5, seed_tasks=$6
+		 WHERE id=$7 AND user_id=$8`,
+		req.Name, req.Description, req.Prompt, variables, req.DefaultDurationDays, seeds, c.Params("id"), sub,
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "update_failed", "detail": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "template_not_found"})
+	}
+
+	return GetTemplateHandler(c)
+}
+
+func DeleteTemplateHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+
+	tag, err := db.Pool.Exec(context.Background(), "DELETE FROM templates WHERE id=$1 AND user_id=$2", c.Params("id"), sub)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "delete_failed", "detail": err.Error()})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "template_not_found"})
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// parseTemplateReq decodes and validates a template definition. It returns a
+// nil body when the request is valid.
+func parseTemplateReq(c *fiber.Ctx) (*templateReq, fiber.Map) {
+	var req templateReq
+	if err := c.BodyParser(&req); err != nil {
+		return nil, fiber.Map{"error": "invalid_body"}
+	}
+
+	var problems []string
+	for _, fe := range validation.StructErrors(&req) {
+		problems = append(problems, fe.Message)
+	}
+	var templateErr *services.TemplateError
+	if err := templatePrompt(&req).Check(); errors.As(err, &templateErr) {
+		problems = append(problems, templateErr.Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, fiber.Map{"error": "invalid_template", "problems": problems}
+	}
+	return &req, nil
+}
+
+func templatePrompt(req *templateReq) *services.PromptTemplate {
+	return &services.PromptTemplate{
+		Prompt:              req.Prompt,
+		Variables:           req.Variables,
+		DefaultDurationDays: req.DefaultDurationDays,
+		SeedTasks:           req.SeedTasks,
+	}
+}
+
+func marshalTemplateLists(req *templateReq) (variables, seeds []byte) {
+	if req.Variables == nil {
+		req.Variables = []services.TemplateVariable{}
+	}
+	if req.SeedTasks == nil {
+		req.SeedTasks = []services.Task{}
+	}
+	variables, _ = json.Marshal(req.Variables)
+	seeds, _ = json.Marshal(req.SeedTasks)
+	return variables, seeds
+}
+
+// loadTemplate returns a built-in template or one owned by userID, or
+// pgx.ErrNoRows.
+func loadTemplate(ctx context.Context, id, userID string) (*db.Template, error) {
+	row := db.Pool.QueryRow(ctx,
+		"SELECT "+templateColumns+" FROM templates WHERE id=$1 AND (user_id IS NULL OR user_id=$2)",
+		id, userID,
+	)
+	return scanTemplate(row)
+}
+
+func scanTemplate(row pgx.Row) (*db.Template, error) {
+	var t db.Template
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.Prompt, &t.Variables, &t.DefaultDurationDays, &t.SeedTasks, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// resolveTemplate loads the template a generation request refers to and
+// checks the variable values against it. Anonymous callers can use built-in
+// templates only. It returns a nil body on success and a nil template when
+// none was requested.
+func resolveTemplate(ctx context.Context, id, userID, goal string, values map[string]string) (*services.PromptTemplate, int, fiber.Map) {
+	if id == "" {
+		if len(values) > 0 {
+			return nil, http.StatusBadRequest, fiber.Map{"error": "template_id_required"}
+		}
+		return nil, http.StatusOK, nil
+	}
+
+	t, err := loadTemplate(ctx, id, userID)
+	if err == pgx.ErrNoRows {
+		return nil, http.StatusNotFound, fiber.Map{"error": "template_not_found"}
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fiber.Map{"error": "db_query_failed", "detail": err.Error()}
+	}
+
+	tmpl := &services.PromptTemplate{Prompt: t.Prompt, DefaultDurationDays: t.DefaultDurationDays}
+	if err := json.Unmarshal(t.Variables, &tmpl.Variables); err != nil {
+		return nil, http.StatusInternalServerError, fiber.Map{"error": "invalid_template", "detail": err.Error()}
+	}
+	if err := json.Unmarshal(t.SeedTasks, &tmpl.SeedTasks); err != nil {
+		return nil, http.StatusInternalServerError, fiber.Map{"error": "invalid_template", "detail": err.Error()}
+	}
+
+	var templateErr *services.TemplateError
+	if _, err := tmpl.Render(goal, values); errors.As(err, &templateErr) {
+		return nil, http.StatusBadRequest, fiber.Map{"error": "invalid_variables", "problems": templateErr.Problems}
+	}
+	return tmpl, http.StatusOK, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// UsageHandler reports the caller's generation window and daily quota
+// without using any of it.
+func UsageHandler(c *fiber.Ctx) error {
+	limiter := c.Locals("rate_limiter").(*services.RateLimiter)
+	client := c.Locals("rate_limit_client").(services.Client)
+
+	usage, err := limiter.Peek(context.Background(), client)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	middleware.SetRateLimitHeaders(c, usage)
+	return c.JSON(usage)
+}
+
+// maxStatsDays bounds the date range of the generation statistics.
+const maxStatsDays = 366
+
+// GenerationStatsHandler returns token usage and estimated cost of the
+// caller's generations.
+func GenerationStatsHandler(c *fiber.Ctx) error {
+	sub, ok := authSubject(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthenticated"})
+	}
+	return generationStatsResponse(c, sub)
+}
+
+// AdminGenerationStatsHandler returns token usage and estimated cost of all
+// generations, with the users that cost the most.
+func AdminGenerationStatsHandler(c *fiber.Ctx) error {
+	return generationStatsResponse(c, "")
+}
+
+func generationStatsResponse(c *fiber.Ctx, userID string) error {
+	from, to, err := statsRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid_range", "detail": err.Error()})
+	}
+	stats, err := loadGenerationStats(context.Background(), userID, from, to)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "db_query_failed", "detail": err.Error()})
+	}
+	return c.JSON(stats)
+}
+
+// statsRange parses the inclusive from and to dates (UTC) into a half-open
+// range. It defaults to the last 30 days.
+func statsRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if toStr != "" {
+		var err error
+		if to, err = time.Parse(calendar.DateLayout, toStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be a YYYY-MM-DD date")
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if fromStr != "" {
+		var err error
+		if from, err = time.Parse(calendar.DateLayout, fromStr); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) >= maxStatsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range must not exceed %d days", maxStatsDays)
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
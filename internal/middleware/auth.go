@@ -2,180 +2,210 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/MicahParks/keyfunc"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
 
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 )
 
 type AuthMiddleware struct {
-	jwks   *keyfunc.JWKS
-	mu     sync.RWMutex
-	config *config.Config
+	config    *config.Config
+	providers *services.OIDCProviders
+	sessions  *services.SessionStore
 }
 
-func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
+func NewAuthMiddleware(cfg *config.Config, providers *services.OIDCProviders, sessions *services.SessionStore) *AuthMiddleware {
 	return &AuthMiddleware{
-		config: cfg,
+		config:    cfg,
+		providers: providers,
+		sessions:  sessions,
 	}
 }
 
-func (a *AuthMiddleware) ensureJWKS(ctx context.Context) error {
-	a.mu.RLock()
-	if a.jwks != nil {
-		a.mu.RUnlock()
-		return nil
-	}
-	a.mu.RUnlock()
+func (a *AuthMiddleware) Cleanup() {
+	a.providers.Cleanup()
+}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
+func (a *AuthMiddleware) AuthRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !a.hasCredentials(c) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+		if status, code := a.authenticate(c); code != "" {
+			return c.Status(status).JSON(fiber.Map{"error": code})
+		}
+		return c.Next()
+	}
+}
 
-	if a.jwks != nil {
-		return nil
+// AuthOptional authenticates the request like AuthRequired when it carries
+// an Authorization header or session cookie, and lets it through anonymously
+// otherwise. An invalid token is rejected rather than ignored, so that a
+// client with an expired session finds out instead of silently losing its
+// plans.
+func (a *AuthMiddleware) AuthOptional() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !a.hasCredentials(c) {
+			return c.Next()
+		}
+		if status, code := a.authenticate(c); code != "" {
+			return c.Status(status).JSON(fiber.Map{"error": code})
+		}
+		return c.Next()
 	}
+}
 
-	issuer := strings.TrimSuffix(a.config.Auth0Issuer, "/")
-	jwksURL := issuer + "/.well-known/jwks.json"
+// hasCredentials reports whether c carries a bearer token or, with
+// sessions enabled, a session cookie.
+func (a *AuthMiddleware) hasCredentials(c *fiber.Ctx) bool {
+	return c.Get("Authorization") != "" || (a.sessions.Enabled() && c.Cookies(services.SessionCookieName) != "")
+}
 
-	options := keyfunc.Options{
-		Ctx:             ctx,
-		RefreshInterval: time.Hour,
-		RefreshTimeout:  10 * time.Second,
-		RefreshErrorHandler: func(err error) {
-			zap.L().Error("JWKS refresh failed", zap.Error(err))
-		},
+// authenticate validates the bearer token of c, or the access token of its
+// session, against the configured issuer that issued it, and stores its
+// subject and claims in the locals. It returns the status and error code to
+// answer with when the token is not valid, and an empty code otherwise.
+func (a *AuthMiddleware) authenticate(c *fiber.Ctx) (int, string) {
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	var tokenStr string
+	if header := c.Get("Authorization"); header != "" {
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" || len(parts[1]) == 0 {
+			return http.StatusUnauthorized, "unauthorized"
+		}
+		tokenStr = parts[1]
+	} else {
+		status, code := a.sessionToken(ctx, c, &tokenStr)
+		if code != "" {
+			return status, code
+		}
 	}
 
-	k, err := keyfunc.Get(jwksURL, options)
+	// The unverified iss only picks the keys to verify the token with;
+	// a token claiming another issuer fails their signature check.
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenStr, jwt.MapClaims{})
 	if err != nil {
-		zap.L().Error("Failed to initialize JWKS", zap.Error(err), zap.String("jwks_url", jwksURL))
-		return err
+		return http.StatusUnauthorized, "unauthorized"
+	}
+	iss, _ := unverified.Claims.(jwt.MapClaims)["iss"].(string)
+	provider := a.providers.ByIssuer(iss)
+	if provider == nil {
+		return http.StatusUnauthorized, "unauthorized"
 	}
 
-	a.jwks = k
-	zap.L().Info("JWKS initialized successfully")
-	return nil
-}
-
-func (a *AuthMiddleware) Cleanup() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	if a.jwks != nil {
-		a.jwks.EndBackground()
-		a.jwks = nil
+	keyfunc, err := provider.Keyfunc(ctx)
+	if err != nil {
+		zap.L().Error("JWKS initialization failed", zap.String("issuer", provider.Name), zap.Error(err))
+		return http.StatusInternalServerError, "authentication_service_unavailable"
 	}
-}
 
-func (a *AuthMiddleware) AuthRequired() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
-		defer cancel()
+	token, err := jwt.Parse(tokenStr, keyfunc)
 
-		if err := a.ensureJWKS(ctx); err != nil {
-			zap.L().Error("JWKS initialization failed", zap.Error(err))
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-				"error": "authentication_service_unavailable",
-			})
-		}
+	if err != nil || !token.Valid {
+		zap.L().Warn("Invalid token provided", zap.Error(err))
+		return http.StatusUnauthorized, "unauthorized"
+	}
 
-		auth := c.Get("Authorization")
-		if auth == "" {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return http.StatusUnauthorized, "unauthorized"
+	}
 
-		parts := strings.SplitN(auth, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
+	if !provider.AcceptsAudience(claims) {
+		return http.StatusUnauthorized, "unauthorized"
+	}
 
-		tokenStr := parts[1]
-		if len(tokenStr) == 0 {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
+	if exp, ok := claims["exp"].(float64); ok {
+		if time.Now().Unix() > int64(exp) {
+			return http.StatusUnauthorized, "token_expired"
 		}
+	} else {
+		return http.StatusUnauthorized, "unauthorized"
+	}
 
-		a.mu.RLock()
-		jwks := a.jwks
-		a.mu.RUnlock()
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return http.StatusUnauthorized, "unauthorized"
+	}
+	c.Locals("auth_sub", a.providers.Subject(provider, sub))
+	c.Locals("auth_claims", claims)
+	c.Locals("auth_issuer", provider.Name)
+	return http.StatusOK, ""
+}
 
-		token, err := jwt.Parse(tokenStr, jwks.Keyfunc)
+// sessionToken sets token to the access token of the request's session,
+// refreshed if need be, and sends the browser the rotated cookie.
+func (a *AuthMiddleware) sessionToken(ctx context.Context, c *fiber.Ctx, token *string) (int, string) {
+	// Cookies travel with cross-site requests that CORS does not preflight;
+	// a custom header cannot be added to those, so requiring it on unsafe
+	// methods rules out CSRF.
+	if !isSafeMethod(c.Method()) && c.Get("X-Requested-With") == "" {
+		return http.StatusForbidden, "csrf_header_required"
+	}
 
-		if err != nil || !token.Valid {
-			zap.L().Warn("Invalid token provided", zap.Error(err))
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
+	st, err := a.sessions.AccessToken(ctx, c.Cookies(services.SessionCookieName))
+	if errors.Is(err, services.ErrInvalidSession) || errors.Is(err, services.ErrSessionReused) {
+		ClearSessionCookie(c, a.config)
+		return http.StatusUnauthorized, "invalid_session"
+	}
+	if err != nil {
+		zap.L().Error("Session lookup failed", zap.Error(err))
+		return http.StatusInternalServerError, "authentication_service_unavailable"
+	}
+	if st.Cookie != "" {
+		SetSessionCookie(c, a.config, st.Cookie, st.ExpiresAt)
+	}
+	c.Locals("session_id", st.SessionID)
+	*token = st.AccessToken
+	return http.StatusOK, ""
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
+func isSafeMethod(method string) bool {
+	return method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions
+}
 
-		if iss, ok := claims["iss"].(string); !ok || iss != a.config.Auth0Issuer {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
+// SetSessionCookie sends the session cookie. It is HttpOnly, so scripts
+// (and injected ones) never see it, and SameSite=Lax.
+func SetSessionCookie(c *fiber.Ctx, cfg *config.Config, value string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     services.SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.SessionCookieDomain,
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   cfg.Env != "development",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
 
-		if aud, ok := claims["aud"].(string); !ok || aud != a.config.Auth0Aud {
-			if audArray, ok := claims["aud"].([]interface{}); ok {
-				found := false
-				for _, audItem := range audArray {
-					if audStr, ok := audItem.(string); ok && audStr == a.config.Auth0Aud {
-						found = true
-						break
-					}
-				}
-				if !found {
-					return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-						"error": "unauthorized",
-					})
-				}
-			} else {
-				return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-					"error": "unauthorized",
-				})
-			}
-		}
+func ClearSessionCookie(c *fiber.Ctx, cfg *config.Config) {
+	SetSessionCookie(c, cfg, "", time.Unix(0, 0))
+}
 
-		if exp, ok := claims["exp"].(float64); ok {
-			if time.Now().Unix() > int64(exp) {
-				return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-					"error": "token_expired",
-				})
+// AdminRequired lets through only the subjects listed in ADMIN_SUBJECTS. It
+// must run after AuthRequired.
+func (a *AuthMiddleware) AdminRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sub, _ := c.Locals("auth_sub").(string)
+		for _, admin := range strings.Split(a.config.AdminSubjects, ",") {
+			if admin = strings.TrimSpace(admin); admin != "" && admin == sub {
+				return c.Next()
 			}
-		} else {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
-		}
-
-		if sub, ok := claims["sub"].(string); ok && sub != "" {
-			c.Locals("auth_sub", sub)
-		} else {
-			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
-				"error": "unauthorized",
-			})
 		}
-
-		c.Locals("auth_claims", claims)
-		return c.Next()
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "forbidden",
+		})
 	}
 }
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -11,3 +12,24 @@ func ConfigMiddleware(cfg *config.Config) fiber.Handler {
 		return c.Next()
 	}
 }
+
+func GeneratorMiddleware(gen services.PlanGenerator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("generator", gen)
+		return c.Next()
+	}
+}
+
+func SessionStoreMiddleware(sessions *services.SessionStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("sessions", sessions)
+		return c.Next()
+	}
+}
+
+func OIDCMiddleware(providers *services.OIDCProviders) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("oidc_providers", providers)
+		return c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
+)
+
+// RateLimit charges each request to the caller's window and daily quota,
+// answering 429 once either is used up. The charge is refunded when the
+// handler answers with a 4xx or reports through RateLimitRefund that it did
+// not call the provider. Signed-in callers are identified by their subject
+// and anonymous ones by IP address, so it must run after any authentication
+// middleware. A user's quota tier is read from the JWT claim named by
+// QUOTA_TIER_CLAIM; users without it are in the "user" tier.
+func RateLimit(limiter *services.RateLimiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		client := rateLimitClient(c)
+		c.Locals("rate_limiter", limiter)
+		c.Locals("rate_limit_client", client)
+
+		usage, err := limiter.Take(context.Background(), client)
+		takenAt := time.Now()
+		var limitErr *services.RateLimitError
+		if errors.As(err, &limitErr) {
+			SetRateLimitHeaders(c, usage)
+			retryAfter := int(limitErr.RetryAfter.Round(time.Second) / time.Second)
+			c.Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+				"error":      limitErr.Kind,
+				"retryAfter": max(retryAfter, 1),
+				"usage":      usage,
+			})
+		}
+		if err != nil {
+			// Quota bookkeeping must not take generation down with it.
+			zap.L().Error("rate limit check failed", zap.String("client", client.Key), zap.Error(err))
+			return c.Next()
+		}
+		SetRateLimitHeaders(c, usage)
+
+		var once sync.Once
+		refund := func() {
+			once.Do(func() {
+				if err := limiter.Refund(context.Background(), client, takenAt); err != nil {
+					zap.L().Error("rate limit refund failed", zap.String("client", client.Key), zap.Error(err))
+				}
+			})
+		}
+		c.Locals("rate_limit_refund", refund)
+
+		err = c.Next()
+		status := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		}
+		if status >= 400 && status < 500 {
+			refund()
+		}
+		return err
+	}
+}
+
+// RateLimitRefund returns a function that gives back the generation RateLimit
+// charged for this request, for handlers that answer without calling the
+// provider. It stays usable after the handler returns, so streaming
+// responses can call it once they know the outcome. Calls after the first
+// do nothing.
+func RateLimitRefund(c *fiber.Ctx) func() {
+	if refund, ok := c.Locals("rate_limit_refund").(func()); ok {
+		return refund
+	}
+	return func() {}
+}
+
+// RateLimitInfo identifies the caller like RateLimit without charging
+// anything, for endpoints that report usage.
+func RateLimitInfo(limiter *services.RateLimiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("rate_limiter", limiter)
+		c.Locals("rate_limit_client", rateLimitClient(c))
+		return c.Next()
+	}
+}
+
+func rateLimitClient(c *fiber.Ctx) services.Client {
+	sub, _ := c.Locals("auth_sub").(string)
+	if sub == "" {
+		return services.Client{Key: "ip:" + c.IP(), Tier: services.TierAnonymous}
+	}
+	tier := services.TierUser
+	tierClaim := c.Locals("config").(*config.Config).QuotaTierClaim
+	if claims, ok := c.Locals("auth_claims").(jwt.MapClaims); ok && tierClaim != "" {
+		if t, ok := claims[tierClaim].(string); ok && t != "" {
+			tier = t
+		}
+	}
+	return services.Client{Key: "user:" + sub, Tier: tier}
+}
+
+// SetRateLimitHeaders reports the limit closest to running out in the
+// RateLimit-* headers, and both limits in RateLimit-Policy.
+func SetRateLimitHeaders(c *fiber.Ctx, usage services.Usage) {
+	now := time.Now()
+	var policies []string
+	limit, remaining, reset := -1, -1, time.Duration(0)
+	if usage.WindowLimit > 0 {
+		policies = append(policies, fmt.Sprintf("%d;w=%d", usage.WindowLimit, usage.WindowSeconds))
+		limit, remaining, reset = usage.WindowLimit, max(usage.WindowRemaining, 0), usage.WindowResetsAt.Sub(now)
+	}
+	if usage.Limit >= 0 {
+		policies = append(policies, fmt.Sprintf("%d;w=86400", usage.Limit))
+		if limit < 0 || usage.Remaining < remaining {
+			limit, remaining, reset = usage.Limit, max(usage.Remaining, 0), usage.ResetsAt.Sub(now)
+		}
+	}
+	if limit < 0 {
+		return
+	}
+	c.Set("RateLimit-Limit", strconv.Itoa(limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(max(int(reset.Round(time.Second)/time.Second), 0)))
+	c.Set("RateLimit-Policy", strings.Join(policies, ", "))
+}
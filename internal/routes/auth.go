@@ -11,9 +11,13 @@ func SetupAuthRoutes(app *fiber.App, authMiddleware *middleware.AuthMiddleware)
 
 	auth.Get("/login", handlers.LoginHandler)
 	auth.Get("/callback", handlers.CallbackHandler)
+	auth.Post("/handoff", handlers.HandoffHandler)
 	auth.Post("/exchange", handlers.ExchangeTokenHandler)
 	auth.Post("/refresh", handlers.RefreshTokenHandler)
 	auth.Get("/logout", handlers.LogoutHandler)
 
 	auth.Get("/profile", authMiddleware.AuthRequired(), handlers.UserProfileHandler)
+	auth.Get("/sessions", authMiddleware.AuthRequired(), handlers.ListSessionsHandler)
+	auth.Delete("/sessions", authMiddleware.AuthRequired(), handlers.RevokeSessionsHandler)
+	auth.Delete("/sessions/:id", authMiddleware.AuthRequired(), handlers.RevokeSessionHandler)
 }
@@ -4,16 +4,26 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 )
 
 func SetupHealthRoutes(app *fiber.App) {
 	app.Get("/health", healthCheckHandler)
 }
 
+// healthCheckHandler reports "degraded" while the provider's circuit is
+// open: plans are still served, but from the fallback.
 func healthCheckHandler(c *fiber.Ctx) error {
+	llm := services.Health(c.Locals("generator").(services.PlanGenerator))
+	status := "ok"
+	if llm.Circuit != nil && llm.Circuit.State != services.CircuitClosed {
+		status = "degraded"
+	}
 	return c.JSON(fiber.Map{
-		"status":    "ok",
+		"status":    status,
 		"timestamp": time.Now().Unix(),
 		"service":   "smart-task-planner-api",
+		"llm":       llm,
 	})
 }
@@ -3,17 +3,45 @@ package routes
 import (
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/handlers"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App, authMiddleware *middleware.AuthMiddleware) {
+func SetupRoutes(app *fiber.App, authMiddleware *middleware.AuthMiddleware, limiter *services.RateLimiter) {
 	SetupHealthRoutes(app)
 	SetupAuthRoutes(app, authMiddleware)
 
+	// The rate limiter keys on auth_sub, so optional authentication has to
+	// run first on the public generation routes.
+	generate := middleware.RateLimit(limiter)
+	optionalAuth := authMiddleware.AuthOptional()
 	api := app.Group("/api")
-	api.Post("/generate", handlers.GenerateHandler)
-	api.Post("/generate/stream", handlers.GenerateStreamHandler)
+	api.Post("/generate", optionalAuth, generate, handlers.GenerateHandler)
+	api.Post("/generate/stream", optionalAuth, generate, handlers.GenerateStreamHandler)
+	api.Get("/usage", optionalAuth, middleware.RateLimitInfo(limiter), handlers.UsageHandler)
 
 	protectedAPI := app.Group("/api", authMiddleware.AuthRequired())
 	protectedAPI.Get("/history", handlers.HistoryHandler)
+	protectedAPI.Get("/plans/:id", handlers.GetPlanHandler)
+	protectedAPI.Put("/plans/:id", handlers.UpdatePlanHandler)
+	protectedAPI.Patch("/plans/:id", handlers.PatchPlanHandler)
+	protectedAPI.Delete("/plans/:id", handlers.DeletePlanHandler)
+	protectedAPI.Post("/plans/:id/refine", generate, handlers.RefinePlanHandler)
+	protectedAPI.Get("/plans/:id/revisions", handlers.ListRevisionsHandler)
+	protectedAPI.Get("/plans/:id/revisions/diff", handlers.DiffRevisionsHandler)
+	protectedAPI.Get("/plans/:id/revisions/:rev", handlers.GetRevisionHandler)
+	protectedAPI.Post("/plans/:id/revisions/:rev/restore", handlers.RestoreRevisionHandler)
+	protectedAPI.Get("/plans/:id/schedule", handlers.ScheduleHandler)
+	protectedAPI.Patch("/plans/:id/tasks/:taskId", handlers.PatchTaskHandler)
+	protectedAPI.Post("/plans/:id/tasks/:taskId/expand", generate, handlers.ExpandTaskHandler)
+	protectedAPI.Get("/templates", handlers.ListTemplatesHandler)
+	protectedAPI.Post("/templates", handlers.CreateTemplateHandler)
+	protectedAPI.Get("/templates/:id", handlers.GetTemplateHandler)
+	protectedAPI.Put("/templates/:id", handlers.UpdateTemplateHandler)
+	protectedAPI.Delete("/templates/:id", handlers.DeleteTemplateHandler)
+	protectedAPI.Get("/settings", handlers.GetSettingsHandler)
+	protectedAPI.Put("/settings", handlers.UpdateSettingsHandler)
+	protectedAPI.Put("/settings/holidays", handlers.UploadHolidaysHandler)
+	protectedAPI.Get("/usage/generations", handlers.GenerationStatsHandler)
+	protectedAPI.Get("/admin/generations", authMiddleware.AdminRequired(), handlers.AdminGenerationStatsHandler)
 }
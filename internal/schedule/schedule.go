@@ -0,0 +1,142 @@
+// Package schedule runs the critical path method over a task dependency
+// graph and maps the resulting working-day offsets onto calendar dates.
+package schedule
+
+import (
+	"time"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+)
+
+// Entry holds the schedule of one task. Offsets are in working days from the
+// project start; a task occupies the half-open range [start, finish).
+type Entry struct {
+	EarliestStart  int
+	EarliestFinish int
+	LatestStart    int
+	LatestFinish   int
+	Slack          int
+	Critical       bool
+	StartDate      time.Time
+	EndDate        time.Time
+}
+
+type Schedule struct {
+	Start        time.Time
+	End          time.Time
+	DurationDays int
+	Entries      []Entry
+	// CriticalPath lists task indices from the first to the last task of the
+	// longest zero-slack chain.
+	CriticalPath []int
+}
+
+// Compute schedules the nodes of g with the given durations, starting on the
+// first working day of cal on or after start. It fails with graph.ErrCycle if
+// g is not acyclic.
+func Compute(g *graph.Graph, durations []int, start time.Time, cal *calendar.Calendar) (*Schedule, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(g.Nodes)
+	entries := make([]Entry, n)
+	dependents := make([][]int, n)
+	for i, deps := range g.Deps {
+		for _, j := range deps {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	total := 0
+	for _, i := range order {
+		es := 0
+		for _, j := range g.Deps[i] {
+			es = max(es, entries[j].EarliestFinish)
+		}
+		entries[i].EarliestStart = es
+		entries[i].EarliestFinish = es + duration(durations, i)
+		total = max(total, entries[i].EarliestFinish)
+	}
+
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		lf := total
+		for _, d := range dependents[i] {
+			lf = min(lf, entries[d].LatestStart)
+		}
+		entries[i].LatestFinish = lf
+		entries[i].LatestStart = lf - duration(durations, i)
+		entries[i].Slack = entries[i].LatestStart - entries[i].EarliestStart
+		entries[i].Critical = entries[i].Slack == 0
+	}
+
+	first, err := cal.NextWorkingDay(start)
+	if err != nil {
+		return nil, err
+	}
+	dateAt := func(offset int) (time.Time, error) {
+		return cal.AddWorkingDays(first, max(offset, 0))
+	}
+
+	for i := range entries {
+		if entries[i].StartDate, err = dateAt(entries[i].EarliestStart); err != nil {
+			return nil, err
+		}
+		if entries[i].EndDate, err = dateAt(entries[i].EarliestFinish - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := dateAt(total - 1)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schedule{
+		Start:        first,
+		End:          end,
+		DurationDays: total,
+		Entries:      entries,
+		CriticalPath: criticalPath(g, entries, order, total),
+	}
+	return s, nil
+}
+
+// criticalPath walks back from a critical task that finishes last through
+// critical predecessors whose finish meets its start.
+func criticalPath(g *graph.Graph, entries []Entry, order []int, total int) []int {
+	current := -1
+	for _, i := range order {
+		if entries[i].Critical && entries[i].EarliestFinish == total {
+			current = i
+			break
+		}
+	}
+
+	var path []int
+	for current >= 0 {
+		path = append(path, current)
+		next := -1
+		for _, j := range g.Deps[current] {
+			if entries[j].Critical && entries[j].EarliestFinish == entries[current].EarliestStart {
+				next = j
+				break
+			}
+		}
+		current = next
+	}
+
+	for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+		path[l], path[r] = path[r], path[l]
+	}
+	return path
+}
+
+func duration(durations []int, i int) int {
+	if i < len(durations) && durations[i] > 0 {
+		return durations[i]
+	}
+	return 1
+}
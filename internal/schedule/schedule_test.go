@@ -0,0 +1,163 @@
+package schedule
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+)
+
+func weekdays(t *testing.T, timezone string, holidays ...string) *calendar.Calendar {
+	t.Helper()
+	hs := make([]calendar.Holiday, len(holidays))
+	for i, h := range holidays {
+		hs[i] = calendar.Holiday{Date: h}
+	}
+	cal, err := calendar.New(timezone, []string{"mon", "tue", "wed", "thu", "fri"}, hs)
+	if err != nil {
+		t.Skip("calendar unavailable:", err)
+	}
+	return cal
+}
+
+func TestCompute(t *testing.T) {
+	// 2026-03-06 is a Friday.
+	friday := time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+
+	chain := []graph.Node{
+		{Name: "a"},
+		{Name: "b", DependsOn: []string{"a"}},
+		{Name: "c", DependsOn: []string{"a"}},
+		{Name: "d", DependsOn: []string{"b", "c"}},
+	}
+
+	tests := []struct {
+		name         string
+		nodes        []graph.Node
+		durations    []int
+		start        time.Time
+		cal          *calendar.Calendar
+		wantStart    string
+		wantEnd      string
+		wantDuration int
+		wantCritical []int
+		wantSlack    []int
+	}{
+		{
+			name:         "every day",
+			nodes:        chain,
+			durations:    []int{1, 3, 1, 2},
+			start:        friday,
+			cal:          calendar.Default(),
+			wantStart:    "2026-03-06",
+			wantEnd:      "2026-03-11",
+			wantDuration: 6,
+			wantCritical: []int{0, 1, 3},
+			wantSlack:    []int{0, 0, 2, 0},
+		},
+		{
+			name:         "skips weekend",
+			nodes:        chain,
+			durations:    []int{1, 3, 1, 2},
+			start:        friday,
+			cal:          weekdays(t, "UTC"),
+			wantStart:    "2026-03-06",
+			wantEnd:      "2026-03-13",
+			wantDuration: 6,
+			wantCritical: []int{0, 1, 3},
+			wantSlack:    []int{0, 0, 2, 0},
+		},
+		{
+			name:         "starts on next working day",
+			nodes:        chain[:1],
+			durations:    []int{2},
+			start:        saturday,
+			cal:          weekdays(t, "UTC"),
+			wantStart:    "2026-03-09",
+			wantEnd:      "2026-03-10",
+			wantDuration: 2,
+			wantCritical: []int{0},
+			wantSlack:    []int{0},
+		},
+		{
+			name:         "skips holiday",
+			nodes:        chain[:1],
+			durations:    []int{3},
+			start:        time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+			cal:          weekdays(t, "UTC", "2026-03-10"),
+			wantStart:    "2026-03-09",
+			wantEnd:      "2026-03-12",
+			wantDuration: 3,
+			wantCritical: []int{0},
+			wantSlack:    []int{0},
+		},
+		{
+			name:         "start in user timezone",
+			nodes:        chain[:1],
+			durations:    []int{1},
+			start:        time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC), // already Saturday in Tokyo
+			cal:          weekdays(t, "Asia/Tokyo"),
+			wantStart:    "2026-03-09",
+			wantEnd:      "2026-03-09",
+			wantDuration: 1,
+			wantCritical: []int{0},
+			wantSlack:    []int{0},
+		},
+		{
+			name:         "missing duration counts as one day",
+			nodes:        chain[:2],
+			durations:    []int{0},
+			start:        friday,
+			cal:          calendar.Default(),
+			wantStart:    "2026-03-06",
+			wantEnd:      "2026-03-07",
+			wantDuration: 2,
+			wantCritical: []int{0, 1},
+			wantSlack:    []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compute(graph.Resolve(tt.nodes), tt.durations, tt.start, tt.cal)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if got := s.Start.Format(calendar.DateLayout); got != tt.wantStart {
+				t.Errorf("Start = %s, want %s", got, tt.wantStart)
+			}
+			if got := s.End.Format(calendar.DateLayout); got != tt.wantEnd {
+				t.Errorf("End = %s, want %s", got, tt.wantEnd)
+			}
+			if s.DurationDays != tt.wantDuration {
+				t.Errorf("DurationDays = %d, want %d", s.DurationDays, tt.wantDuration)
+			}
+			if !slices.Equal(s.CriticalPath, tt.wantCritical) {
+				t.Errorf("CriticalPath = %v, want %v", s.CriticalPath, tt.wantCritical)
+			}
+			slack := make([]int, len(s.Entries))
+			for i, e := range s.Entries {
+				slack[i] = e.Slack
+				if e.EndDate.Before(e.StartDate) {
+					t.Errorf("entry %d ends %s before it starts %s", i, e.EndDate, e.StartDate)
+				}
+			}
+			if !slices.Equal(slack, tt.wantSlack) {
+				t.Errorf("slack = %v, want %v", slack, tt.wantSlack)
+			}
+		})
+	}
+}
+
+func TestComputeCycle(t *testing.T) {
+	g := graph.Resolve([]graph.Node{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	})
+	if _, err := Compute(g, []int{1, 1}, time.Now(), calendar.Default()); !errors.Is(err, graph.ErrCycle) {
+		t.Fatalf("Compute() error = %v, want graph.ErrCycle", err)
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,10 +15,34 @@ import (
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/middleware"
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/routes"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/services"
 )
 
 func NewApp(cfg *config.Config) (*fiber.App, *middleware.AuthMiddleware) {
-	authMiddleware := middleware.NewAuthMiddleware(cfg)
+	providers, err := services.NewOIDCProviders(cfg)
+	if err != nil {
+		zap.L().Fatal("invalid OIDC issuer configuration", zap.Error(err))
+	}
+	sessions := services.NewSessionStore(cfg, providers)
+	authMiddleware := middleware.NewAuthMiddleware(cfg, providers, sessions)
+
+	generator, err := services.NewPlanGenerator(cfg)
+	if err != nil {
+		zap.L().Fatal("plan generator init failed", zap.Error(err))
+	}
+
+	quotas, err := services.ParseQuotas(cfg.GenerationQuotas)
+	if err != nil {
+		zap.L().Fatal("invalid GENERATION_QUOTAS", zap.Error(err))
+	}
+	limiter := services.NewRateLimiter(cfg.RateLimitRequests, time.Duration(cfg.RateLimitPeriodSeconds)*time.Second, quotas)
+
+	proxyHeader := ""
+	if cfg.TrustProxy {
+		// Only trust the header when a proxy in front of us sets it;
+		// otherwise clients could pick their own rate limit key.
+		proxyHeader = cfg.ProxyHeader
+	}
 
 	app := fiber.New(fiber.Config{
 		ReadTimeout:           30 * time.Second,
@@ -28,6 +53,8 @@ func NewApp(cfg *config.Config) (*fiber.App, *middleware.AuthMiddleware) {
 		AppName:               "SmartTracker API v1.0",
 		ErrorHandler:          customErrorHandler,
 		DisableStartupMessage: cfg.Env == "production",
+		ProxyHeader:           proxyHeader,
+		EnableIPValidation:    true,
 	})
 
 	app.Use(recover.New(recover.Config{
@@ -42,16 +69,22 @@ func NewApp(cfg *config.Config) (*fiber.App, *middleware.AuthMiddleware) {
 		ReferrerPolicy:     "strict-origin-when-cross-origin",
 	}))
 
+	// Credentials (the login state and session cookies) can only be allowed for an
+	// explicit list of origins.
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.AllowedOrigins,
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With",
-		AllowCredentials: false,
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,Cache-Control",
+		ExposeHeaders:    "RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After",
+		AllowCredentials: cfg.AllowedOrigins != "" && !strings.Contains(cfg.AllowedOrigins, "*"),
 		MaxAge:           86400,
 	}))
 
 	app.Use(requestid.New())
 	app.Use(middleware.ConfigMiddleware(cfg))
+	app.Use(middleware.GeneratorMiddleware(generator))
+	app.Use(middleware.OIDCMiddleware(providers))
+	app.Use(middleware.SessionStoreMiddleware(sessions))
 
 	if cfg.Env != "production" {
 		app.Use(logger.New(logger.Config{
@@ -59,7 +92,7 @@ func NewApp(cfg *config.Config) (*fiber.App, *middleware.AuthMiddleware) {
 		}))
 	}
 
-	routes.SetupRoutes(app, authMiddleware)
+	routes.SetupRoutes(app, authMiddleware, limiter)
 
 	zap.L().Info("routes registered successfully")
 	return app, authMiddleware
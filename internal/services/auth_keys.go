@@ -0,0 +1,57 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+)
+
+// AuthKey derives a 32-byte key for purpose from cfg.AuthSecret.
+func AuthKey(cfg *config.Config, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(cfg.AuthSecret()))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// SealToken encrypts token with AES-GCM for storage; the nonce is
+// prepended.
+func SealToken(cfg *config.Config, token string) ([]byte, error) {
+	gcm, err := tokenCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, []byte(token), nil), nil
+}
+
+func OpenToken(cfg *config.Config, sealed []byte) (string, error) {
+	gcm, err := tokenCipher(cfg)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("sealed token too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func tokenCipher(cfg *config.Config) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(AuthKey(cfg, "token-encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
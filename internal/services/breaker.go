@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned without calling the provider while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("provider circuit breaker is open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitState is a snapshot of a circuit breaker for health output.
+type CircuitState struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+}
+
+// circuitBreaker opens after threshold consecutive provider failures and
+// fails fast for cooldown. It then lets a single probe through: success
+// closes it, failure opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+// allow reports whether a call may go to the provider.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		return true
+	case CircuitHalfOpen:
+		// A probe is already in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		if b.state != CircuitClosed {
+			zap.L().Info("provider circuit closed")
+		}
+		b.state, b.failures = CircuitClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			zap.L().Warn("provider circuit opened", zap.Int("consecutive_failures", b.failures), zap.Duration("cooldown", b.cooldown))
+		}
+		b.state, b.openedAt = CircuitOpen, time.Now()
+	}
+}
+
+// release hands back a half-open probe whose outcome says nothing about the
+// provider, such as a canceled request.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.state = CircuitOpen
+		b.openedAt = time.Now().Add(-b.cooldown)
+	}
+}
+
+func (b *circuitBreaker) snapshot() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := CircuitState{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != CircuitClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		s.OpenedAt, s.RetryAt = &openedAt, &retryAt
+	}
+	return s
+}
+
+// breakerGenerator guards a provider with a circuit breaker. Only failures
+// that mean the provider is unavailable count; rejected prompts and output
+// that does not match the schema do not.
+type breakerGenerator struct {
+	next    PlanGenerator
+	breaker *circuitBreaker
+}
+
+func (g *breakerGenerator) Name() string          { return g.next.Name() }
+func (g *breakerGenerator) Model() string         { return g.next.Model() }
+func (g *breakerGenerator) Unwrap() PlanGenerator { return g.next }
+
+func (g *breakerGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	if !g.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	res, err := g.next.GeneratePlan(ctx, req)
+	switch {
+	case err == nil:
+		g.breaker.record(false)
+	case errors.Is(ctx.Err(), context.Canceled):
+		g.breaker.release()
+	case providerUnavailable(err):
+		g.breaker.record(true)
+	default:
+		// The provider answered; it is up.
+		g.breaker.record(false)
+	}
+	return res, err
+}
+
+// providerUnavailable reports whether err means the provider could not
+// answer, rather than answering badly.
+func providerUnavailable(err error) bool {
+	switch FailureReason(err) {
+	case ReasonTimeout, ReasonRateLimited, ReasonProviderUnavailable:
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// ProviderHealth describes the configured provider for the health endpoint.
+type ProviderHealth struct {
+	Provider string        `json:"provider"`
+	Model    string        `json:"model"`
+	Circuit  *CircuitState `json:"circuit,omitempty"`
+}
+
+// Health reports on the provider behind gen, including its circuit breaker
+// when there is one.
+func Health(gen PlanGenerator) ProviderHealth {
+	h := ProviderHealth{Provider: gen.Name(), Model: gen.Model()}
+	for g := gen; g != nil; {
+		if b, ok := g.(*breakerGenerator); ok {
+			state := b.breaker.snapshot()
+			h.Circuit = &state
+			break
+		}
+		u, ok := g.(interface{ Unwrap() PlanGenerator })
+		if !ok {
+			break
+		}
+		g = u.Unwrap()
+	}
+	return h
+}
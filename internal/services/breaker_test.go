@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// expire moves the breaker's opening back past its cooldown.
+func expire(b *circuitBreaker) {
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-2 * b.cooldown)
+	b.mu.Unlock()
+}
+
+func wantCircuit(t *testing.T, b *circuitBreaker, state string, failures int) {
+	t.Helper()
+	got := b.snapshot()
+	if got.State != state || got.ConsecutiveFailures != failures {
+		t.Fatalf("breaker is %s after %d failures, want %s after %d", got.State, got.ConsecutiveFailures, state, failures)
+	}
+}
+
+func TestCircuitBreakerThreshold(t *testing.T) {
+	b := newCircuitBreaker(3, time.Hour)
+	b.record(true)
+	b.record(true)
+	wantCircuit(t, b, CircuitClosed, 2)
+
+	// A success in between starts the count again.
+	b.record(false)
+	b.record(true)
+	b.record(true)
+	wantCircuit(t, b, CircuitClosed, 2)
+	if !b.allow() {
+		t.Fatal("closed breaker refused a call")
+	}
+
+	b.record(true)
+	wantCircuit(t, b, CircuitOpen, 3)
+	if b.allow() {
+		t.Fatal("open breaker allowed a call before its cooldown")
+	}
+	if s := b.snapshot(); s.OpenedAt == nil || s.RetryAt == nil || s.RetryAt.Sub(*s.OpenedAt) != time.Hour {
+		t.Fatalf("open snapshot = %+v, want openedAt and retryAt an hour apart", s)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+	b.record(true)
+	wantCircuit(t, b, CircuitOpen, 1)
+
+	expire(b)
+	if !b.allow() {
+		t.Fatal("breaker refused the probe after its cooldown")
+	}
+	wantCircuit(t, b, CircuitHalfOpen, 1)
+	if b.allow() {
+		t.Fatal("breaker allowed a second call while the probe is in flight")
+	}
+
+	// A failed probe opens the breaker for another cooldown.
+	b.record(true)
+	wantCircuit(t, b, CircuitOpen, 2)
+	if b.allow() {
+		t.Fatal("breaker allowed a call right after a failed probe")
+	}
+
+	// A successful probe closes it.
+	expire(b)
+	if !b.allow() {
+		t.Fatal("breaker refused the second probe")
+	}
+	b.record(false)
+	wantCircuit(t, b, CircuitClosed, 0)
+	if s := b.snapshot(); s.OpenedAt != nil || s.RetryAt != nil {
+		t.Fatalf("closed snapshot = %+v, want no times", s)
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+	b.record(true)
+	expire(b)
+	if !b.allow() {
+		t.Fatal("breaker refused the probe")
+	}
+
+	// A released probe lets the next call probe straight away.
+	b.release()
+	wantCircuit(t, b, CircuitOpen, 1)
+	if !b.allow() {
+		t.Fatal("breaker refused a probe after release")
+	}
+	wantCircuit(t, b, CircuitHalfOpen, 1)
+
+	// Release outside half-open changes nothing.
+	closed := newCircuitBreaker(1, time.Hour)
+	closed.release()
+	wantCircuit(t, closed, CircuitClosed, 0)
+}
+
+func TestBreakerGenerator(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		err       error
+		wantState string
+		// wantAllow is whether the next call gets through.
+		wantAllow bool
+	}{
+		{name: "success", ctx: context.Background(), wantState: CircuitClosed, wantAllow: true},
+		{name: "server error", ctx: context.Background(), err: &ProviderError{StatusCode: 503}, wantState: CircuitOpen},
+		{name: "rate limited", ctx: context.Background(), err: &ProviderError{StatusCode: 429}, wantState: CircuitOpen},
+		{name: "timeout", ctx: context.Background(), err: context.DeadlineExceeded, wantState: CircuitOpen},
+		{name: "rejected prompt", ctx: context.Background(), err: &ProviderError{StatusCode: 400}, wantState: CircuitClosed, wantAllow: true},
+		{name: "canceled probe", ctx: canceled, err: context.Canceled, wantState: CircuitOpen, wantAllow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each case runs as the probe of a breaker that has cooled down.
+			g := &breakerGenerator{next: stubGenerator{err: tt.err}, breaker: newCircuitBreaker(1, time.Hour)}
+			g.breaker.record(true)
+			expire(g.breaker)
+
+			g.GeneratePlan(tt.ctx, PlanRequest{})
+			if got := g.breaker.snapshot().State; got != tt.wantState {
+				t.Fatalf("breaker is %s, want %s", got, tt.wantState)
+			}
+			if got := g.breaker.allow(); got != tt.wantAllow {
+				t.Fatalf("allow() after the probe = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+
+	g := &breakerGenerator{next: stubGenerator{}, breaker: newCircuitBreaker(1, time.Hour)}
+	g.breaker.record(true)
+	if _, err := g.GeneratePlan(context.Background(), PlanRequest{}); err != ErrCircuitOpen {
+		t.Fatalf("GeneratePlan() on an open breaker = %v, want ErrCircuitOpen", err)
+	}
+}
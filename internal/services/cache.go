@@ -0,0 +1,251 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+// promptVersion is part of every cache key. Bump it whenever the prompts or
+// the task schema change so that plans built from old prompts are not
+// served again.
+const promptVersion = "1"
+
+// CacheEntry is a generated plan stored under a cache key.
+type CacheEntry struct {
+	Tasks     []Task
+	Provider  string
+	Model     string
+	ExpiresAt time.Time
+}
+
+// CacheStore persists cache entries. Get returns nil for missing or expired
+// keys.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*CacheEntry, error)
+	Put(ctx context.Context, key string, entry CacheEntry) error
+}
+
+// normalizeGoal folds case and runs of whitespace so that goals differing
+// only in those share a cache entry.
+func normalizeGoal(goal string) string {
+	return strings.ToLower(strings.Join(strings.Fields(goal), " "))
+}
+
+// cacheKey identifies the output of a fresh plan request for model. The
+// whole template is part of the key since its seed tasks, default duration
+// and variable defaults all shape the prompt. It returns false for requests
+// that are not cached: refinements and expansions depend on an existing plan.
+func cacheKey(req PlanRequest, provider, model string) (string, bool) {
+	if len(req.Existing) > 0 || req.Expand != nil {
+		return "", false
+	}
+	opts := req.Options
+	// DeadlineMode only affects what happens after generation.
+	opts.DeadlineMode = ""
+	today := ""
+	if opts.Deadline != "" && !opts.Today.IsZero() {
+		// The deadline prompt counts the days left from today.
+		today = opts.Today.Format("2006-01-02")
+	}
+	data, err := json.Marshal(struct {
+		Version   string            `json:"v"`
+		Provider  string            `json:"provider"`
+		Model     string            `json:"model"`
+		Goal      string            `json:"goal"`
+		Options   GenerationOptions `json:"options"`
+		Today     string            `json:"today,omitempty"`
+		Template  *PromptTemplate   `json:"template,omitempty"`
+		Variables map[string]string `json:"variables,omitempty"`
+	}{promptVersion, provider, model, normalizeGoal(req.Goal), opts, today, req.Template, req.Variables})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// cachingGenerator answers repeated plan requests from an in-memory LRU
+// backed by a CacheStore. Only plans that came from the provider are
+// stored; hits are reported with SourceCache.
+type cachingGenerator struct {
+	next  PlanGenerator
+	store CacheStore
+	lru   *lruCache
+	ttl   time.Duration
+}
+
+func (g *cachingGenerator) Name() string          { return g.next.Name() }
+func (g *cachingGenerator) Model() string         { return g.next.Model() }
+func (g *cachingGenerator) Unwrap() PlanGenerator { return g.next }
+
+func (g *cachingGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	key, ok := cacheKey(req, g.next.Name(), g.next.Model())
+	if !ok {
+		return g.next.GeneratePlan(ctx, req)
+	}
+
+	if !req.CacheBypass {
+		if entry := g.lookup(ctx, key); entry != nil {
+			return &PlanResult{
+				Tasks:    cloneTasks(entry.Tasks),
+				Source:   SourceCache,
+				Provider: entry.Provider,
+				Model:    entry.Model,
+			}, nil
+		}
+	}
+
+	res, err := g.next.GeneratePlan(ctx, req)
+	if err != nil || res.Source != SourceLLM {
+		return res, err
+	}
+	entry := CacheEntry{
+		Tasks:     cloneTasks(res.Tasks),
+		Provider:  res.Provider,
+		Model:     res.Model,
+		ExpiresAt: time.Now().Add(g.ttl),
+	}
+	g.lru.put(key, entry)
+	if g.store != nil {
+		if err := g.store.Put(ctx, key, entry); err != nil {
+			zap.L().Warn("generation cache write failed", zap.Error(err))
+		}
+	}
+	return res, nil
+}
+
+func (g *cachingGenerator) lookup(ctx context.Context, key string) *CacheEntry {
+	if entry := g.lru.get(key); entry != nil {
+		return entry
+	}
+	if g.store == nil {
+		return nil
+	}
+	entry, err := g.store.Get(ctx, key)
+	if err != nil {
+		zap.L().Warn("generation cache read failed", zap.Error(err))
+		return nil
+	}
+	if entry != nil {
+		g.lru.put(key, *entry)
+	}
+	return entry
+}
+
+func cloneTasks(tasks []Task) []Task {
+	out := make([]Task, len(tasks))
+	for i, t := range tasks {
+		out[i] = t
+		out[i].DependsOn = append([]string{}, t.DependsOn...)
+	}
+	return out
+}
+
+// lruCache is a fixed-size, expiring in-memory cache of plans.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) *CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.entry.ExpiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil
+	}
+	c.order.MoveToFront(el)
+	entry := item.entry
+	return &entry
+}
+
+func (c *lruCache) put(key string, entry CacheEntry) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruItem).key)
+	}
+}
+
+// PostgresCacheStore keeps cache entries in the generation_cache table so
+// they survive restarts and are shared between instances.
+type PostgresCacheStore struct{}
+
+func (PostgresCacheStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	var entry CacheEntry
+	var tasks []byte
+	err := db.Pool.QueryRow(ctx,
+		"SELECT tasks, provider, model, expires_at FROM generation_cache WHERE key=$1 AND expires_at > now()",
+		key,
+	).Scan(&tasks, &entry.Provider, &entry.Model, &entry.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tasks, &entry.Tasks); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (PostgresCacheStore) Put(ctx context.Context, key string, entry CacheEntry) error {
+	tasks, err := json.Marshal(entry.Tasks)
+	if err != nil {
+		return err
+	}
+	// Expired rows are cleared on write; a miss is about to pay for a model
+	// call anyway.
+	if _, err := db.Pool.Exec(ctx, "DELETE FROM generation_cache WHERE expires_at <= now()"); err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO generation_cache (key, tasks, provider, model, expires_at)
+		 VALUES ($1, $2::jsonb, $3, $4, $5)
+		 ON CONFLICT (key) DO UPDATE SET tasks=EXCLUDED.tasks, provider=EXCLUDED.provider,
+		   model=EXCLUDED.model, created_at=now(), expires_at=EXCLUDED.expires_at`,
+		key, tasks, entry.Provider, entry.Model, entry.ExpiresAt,
+	)
+	return err
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	today := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	template := func(mod func(*PromptTemplate)) *PromptTemplate {
+		tmpl := &PromptTemplate{
+			Prompt:              "Plan {{.goal}} at {{.level}}.",
+			Variables:           []TemplateVariable{{Name: "level", Default: "beginner"}},
+			DefaultDurationDays: 2,
+			SeedTasks:           []Task{{Task: "Kickoff", DurationDays: 1}},
+		}
+		if mod != nil {
+			mod(tmpl)
+		}
+		return tmpl
+	}
+	base := PlanRequest{Goal: "Learn Go", Template: template(nil)}
+	baseKey, ok := cacheKey(base, "openai", "gpt")
+	if !ok {
+		t.Fatal("cacheKey() did not cache a fresh plan request")
+	}
+
+	tests := []struct {
+		name     string
+		req      PlanRequest
+		provider string
+		model    string
+		same     bool
+	}{
+		{name: "goal case and spacing", req: PlanRequest{Goal: "  learn   GO ", Template: template(nil)}, same: true},
+		{name: "deadline mode", req: PlanRequest{Goal: "Learn Go", Template: template(nil), Options: GenerationOptions{DeadlineMode: DeadlineModeCompress}}, same: true},
+		{name: "today without deadline", req: PlanRequest{Goal: "Learn Go", Template: template(nil), Options: GenerationOptions{Today: today}}, same: true},
+		{name: "goal", req: PlanRequest{Goal: "Learn Rust", Template: template(nil)}},
+		{name: "provider", req: base, provider: "anthropic"},
+		{name: "model", req: base, model: "gpt-mini"},
+		{name: "option", req: PlanRequest{Goal: "Learn Go", Template: template(nil), Options: GenerationOptions{TeamSize: 2}}},
+		{name: "variables", req: PlanRequest{Goal: "Learn Go", Template: template(nil), Variables: map[string]string{"level": "expert"}}},
+		{name: "no template", req: PlanRequest{Goal: "Learn Go"}},
+		{name: "template prompt", req: PlanRequest{Goal: "Learn Go", Template: template(func(p *PromptTemplate) { p.Prompt = "Study {{.goal}} at {{.level}}." })}},
+		{name: "template seed tasks", req: PlanRequest{Goal: "Learn Go", Template: template(func(p *PromptTemplate) { p.SeedTasks = []Task{{Task: "Setup", DurationDays: 1}} })}},
+		{name: "template seed duration", req: PlanRequest{Goal: "Learn Go", Template: template(func(p *PromptTemplate) { p.SeedTasks[0].DurationDays = 3 })}},
+		{name: "template default duration", req: PlanRequest{Goal: "Learn Go", Template: template(func(p *PromptTemplate) { p.DefaultDurationDays = 5 })}},
+		{name: "template variable default", req: PlanRequest{Goal: "Learn Go", Template: template(func(p *PromptTemplate) { p.Variables[0].Default = "expert" })}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, model := "openai", "gpt"
+			if tt.provider != "" {
+				provider = tt.provider
+			}
+			if tt.model != "" {
+				model = tt.model
+			}
+			key, ok := cacheKey(tt.req, provider, model)
+			if !ok {
+				t.Fatal("cacheKey() did not cache a fresh plan request")
+			}
+			if (key == baseKey) != tt.same {
+				t.Fatalf("cacheKey() same as base = %v, want %v", key == baseKey, tt.same)
+			}
+		})
+	}
+}
+
+func TestCacheKeyDeadlineDependsOnToday(t *testing.T) {
+	req := PlanRequest{Goal: "Learn Go", Options: GenerationOptions{Deadline: "2026-03-20", Today: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}}
+	first, _ := cacheKey(req, "openai", "gpt")
+	req.Options.Today = req.Options.Today.AddDate(0, 0, 1)
+	second, _ := cacheKey(req, "openai", "gpt")
+	if first == second {
+		t.Fatal("cacheKey() ignores today for a deadline request")
+	}
+}
+
+func TestCacheKeySkipsEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		req  PlanRequest
+	}{
+		{name: "refine", req: PlanRequest{Goal: "Learn Go", Existing: []Task{{Task: "Read", DurationDays: 1}}, Instruction: "shorter"}},
+		{name: "expand", req: PlanRequest{Goal: "Learn Go", Existing: []Task{{Task: "Read", DurationDays: 1}}, Expand: &Task{Task: "Read", DurationDays: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := cacheKey(tt.req, "openai", "gpt"); ok {
+				t.Fatal("cacheKey() cached an edit of an existing plan")
+			}
+		})
+	}
+}
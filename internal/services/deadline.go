@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/schedule"
+)
+
+const (
+	DeadlineModeWarn     = "warn"
+	DeadlineModeCompress = "compress"
+)
+
+// maxCompressAttempts bounds the extra generator calls made to fit a plan
+// into its deadline.
+const maxCompressAttempts = 2
+
+// LateTask is a task scheduled to end after the deadline.
+type LateTask struct {
+	Task      string `json:"task"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Critical  bool   `json:"critical"`
+}
+
+// DeadlineFit compares a plan's critical-path schedule with its deadline.
+type DeadlineFit struct {
+	Fits         bool       `json:"fits"`
+	Deadline     string     `json:"deadline"`
+	Start        string     `json:"start"`
+	ProjectedEnd string     `json:"projectedEnd"`
+	DurationDays int        `json:"durationDays"`
+	OverdueDays  int        `json:"overdueDays"`
+	CriticalPath []string   `json:"criticalPath"`
+	LateTasks    []LateTask `json:"lateTasks"`
+	Compressed   bool       `json:"compressed"`
+	Attempts     int        `json:"attempts"`
+}
+
+// CheckDeadline schedules tasks from start on cal and reports whether they
+// finish by deadline. Overdue days are calendar days past the deadline.
+func CheckDeadline(tasks []Task, start, deadline time.Time, cal *calendar.Calendar) (*DeadlineFit, error) {
+	nodes := make([]graph.Node, len(tasks))
+	durations := make([]int, len(tasks))
+	for i, t := range tasks {
+		nodes[i] = graph.Node{Name: t.Task, DependsOn: t.DependsOn}
+		durations[i] = t.DurationDays
+	}
+	g := graph.Resolve(nodes)
+	sched, err := schedule.Compute(g, durations, start, cal)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &DeadlineFit{
+		Fits:         !sched.End.After(deadline),
+		Deadline:     deadline.Format(calendar.DateLayout),
+		Start:        sched.Start.Format(calendar.DateLayout),
+		ProjectedEnd: sched.End.Format(calendar.DateLayout),
+		DurationDays: sched.DurationDays,
+		CriticalPath: make([]string, len(sched.CriticalPath)),
+		LateTasks:    []LateTask{},
+	}
+	if !fit.Fits {
+		fit.OverdueDays = int(sched.End.Sub(deadline).Round(24*time.Hour).Hours() / 24)
+	}
+	for k, i := range sched.CriticalPath {
+		fit.CriticalPath[k] = tasks[i].Task
+	}
+	for i, e := range sched.Entries {
+		if e.EndDate.After(deadline) {
+			fit.LateTasks = append(fit.LateTasks, LateTask{
+				Task:      tasks[i].Task,
+				StartDate: e.StartDate.Format(calendar.DateLayout),
+				EndDate:   e.EndDate.Format(calendar.DateLayout),
+				Critical:  e.Critical,
+			})
+		}
+	}
+	return fit, nil
+}
+
+// FitDeadline checks res against the deadline in req.Options. In compress
+// mode a plan that overshoots is sent back to gen with an instruction to
+// shorten its critical path, up to maxCompressAttempts times; the result
+// closest to the deadline is returned, with the usage of every call. Fallback
+// plans are only checked. The returned fit is nil when no deadline was
+// requested.
+func FitDeadline(ctx context.Context, gen PlanGenerator, req PlanRequest, res *PlanResult) (*PlanResult, *DeadlineFit, error) {
+	opts := req.Options
+	if opts.Deadline == "" {
+		return res, nil, nil
+	}
+	cal := opts.Calendar
+	if cal == nil {
+		cal = calendar.Default()
+	}
+	today := opts.Today
+	if today.IsZero() {
+		today = cal.Today()
+	}
+	deadline, err := time.ParseInLocation(calendar.DateLayout, opts.Deadline, cal.Location)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fit, err := CheckDeadline(res.Tasks, today, deadline, cal)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fit.Fits || opts.DeadlineMode != DeadlineModeCompress || res.Source == SourceFallback {
+		return res, fit, nil
+	}
+
+	best, bestFit := res, fit
+	usage, latency := res.Usage, res.Latency
+	attempts := 0
+	for !bestFit.Fits && attempts < maxCompressAttempts {
+		attempts++
+		compressReq := PlanRequest{
+			Goal:        req.Goal,
+			Existing:    best.Tasks,
+			Instruction: compressInstruction(bestFit),
+			Options:     GenerationOptions{Language: opts.Language, HoursPerDay: opts.HoursPerDay, TeamSize: opts.TeamSize},
+		}
+		compressed, err := gen.GeneratePlan(ctx, compressReq)
+		if err == nil {
+			usage, latency = usage.Add(compressed.Usage), latency+compressed.Latency
+		} else {
+			failedUsage, failedLatency := generationCost(err)
+			usage, latency = usage.Add(failedUsage), latency+failedLatency
+		}
+		if err != nil || compressed.Source == SourceFallback {
+			zap.L().Warn("plan compression failed", zap.Int("attempt", attempts), zap.Error(err))
+			break
+		}
+		compressedFit, err := CheckDeadline(compressed.Tasks, today, deadline, cal)
+		if err != nil {
+			zap.L().Warn("compressed plan cannot be scheduled", zap.Int("attempt", attempts), zap.Error(err))
+			break
+		}
+		if compressedFit.DurationDays < bestFit.DurationDays {
+			best, bestFit = compressed, compressedFit
+			bestFit.Compressed = true
+		}
+	}
+	bestFit.Attempts = attempts
+	best.Usage, best.Latency = usage, latency
+	return best, bestFit, nil
+}
+
+func compressInstruction(fit *DeadlineFit) string {
+	return fmt.Sprintf(
+		"The plan takes %d working days from %s and ends on %s, %d days after the deadline of %s. "+
+			"Shorten it so it ends by the deadline: remove dependencies that are not strictly needed so tasks can run in parallel, "+
+			"shorten or merge tasks, and drop optional work. The longest chain is: %s.",
+		fit.DurationDays, fit.Start, fit.ProjectedEnd, fit.OverdueDays, fit.Deadline, strings.Join(fit.CriticalPath, " -> "),
+	)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFitDeadlineUsage(t *testing.T) {
+	today := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	first := TokenUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, CostUSD: 0.01}
+	compress := TokenUsage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220, CostUSD: 0.02}
+	both := first.Add(compress)
+
+	tests := []struct {
+		name           string
+		mode           string
+		next           stubGenerator
+		wantUsage      TokenUsage
+		wantCompressed bool
+		wantAttempts   int
+	}{
+		{
+			name:      "warn makes no calls",
+			mode:      DeadlineModeWarn,
+			wantUsage: first,
+		},
+		{
+			name:           "compressed plan",
+			mode:           DeadlineModeCompress,
+			next:           stubGenerator{res: &PlanResult{Source: SourceLLM, Tasks: []Task{{Task: "Ship", DurationDays: 5}}, Usage: compress}},
+			wantUsage:      both,
+			wantCompressed: true,
+			wantAttempts:   1,
+		},
+		{
+			name:         "failed compression keeps its usage",
+			mode:         DeadlineModeCompress,
+			next:         stubGenerator{err: &GenerationError{Err: errors.New("truncated"), Usage: compress}},
+			wantUsage:    both,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := PlanRequest{Goal: "Ship", Options: GenerationOptions{Deadline: "2026-03-15", DeadlineMode: tt.mode, Today: today}}
+			res := &PlanResult{Source: SourceLLM, Tasks: []Task{{Task: "Ship", DurationDays: 30}}, Usage: first}
+
+			got, fit, err := FitDeadline(context.Background(), tt.next, req, res)
+			if err != nil {
+				t.Fatalf("FitDeadline() error = %v", err)
+			}
+			if got.Usage != tt.wantUsage {
+				t.Fatalf("Usage = %+v, want %+v", got.Usage, tt.wantUsage)
+			}
+			if fit.Compressed != tt.wantCompressed || fit.Attempts != tt.wantAttempts {
+				t.Fatalf("fit compressed=%v after %d attempts, want %v after %d", fit.Compressed, fit.Attempts, tt.wantCompressed, tt.wantAttempts)
+			}
+		})
+	}
+}
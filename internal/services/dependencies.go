@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+)
+
+type DependencyMode string
+
+const (
+	// DependencyModeRepair rewrites fuzzy matches to canonical task names and
+	// drops unknown, self and cycle-closing references.
+	DependencyModeRepair DependencyMode = "repair"
+	// DependencyModeReject fails generation when the graph has blocking
+	// issues or a reference only matches a task by similarity.
+	DependencyModeReject DependencyMode = "reject"
+)
+
+type DependencyError struct {
+	Issues []graph.Issue
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("plan has %d invalid dependencies", len(e.Issues))
+}
+
+// CheckDependencies resolves the depends_on references of tasks and either
+// repairs or rejects the plan according to mode. The returned tasks always
+// reference other tasks by their exact names.
+func CheckDependencies(tasks []Task, mode DependencyMode) ([]Task, []graph.Issue, error) {
+	nodes := make([]graph.Node, len(tasks))
+	for i, t := range tasks {
+		nodes[i] = graph.Node{Name: t.Task, DependsOn: t.DependsOn}
+	}
+
+	g := graph.Resolve(nodes)
+	if mode == DependencyModeReject {
+		if rejected := rejectedIssues(g); len(rejected) > 0 {
+			return nil, g.Issues, &DependencyError{Issues: rejected}
+		}
+	}
+	if len(g.Issues) > 0 {
+		zap.L().Info("repaired plan dependencies", zap.Int("issues", len(g.Issues)))
+	}
+
+	repaired := g.Repair()
+	out := make([]Task, len(tasks))
+	for i, t := range tasks {
+		t.DependsOn = repaired[i].DependsOn
+		out[i] = t
+	}
+	return out, g.Issues, nil
+}
+
+// rejectedIssues returns the issues that fail a plan in reject mode: the
+// blocking ones and fuzzy matches, which repair mode would have to guess.
+func rejectedIssues(g *graph.Graph) []graph.Issue {
+	var res []graph.Issue
+	for _, issue := range g.Issues {
+		if issue.Kind.Blocking() || issue.Kind == graph.IssueFuzzyMatch {
+			res = append(res, issue)
+		}
+	}
+	return res
+}
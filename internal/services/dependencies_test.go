@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/graph"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	tasks := func(ref string) []Task {
+		return []Task{
+			{Task: "Research topic", DurationDays: 1, DependsOn: []string{}},
+			{Task: "Write outline", DurationDays: 1, DependsOn: []string{ref}},
+		}
+	}
+	tests := []struct {
+		name     string
+		ref      string
+		mode     DependencyMode
+		wantDeps []string
+		wantKind graph.IssueKind
+	}{
+		{name: "repair exact", ref: "Research topic", mode: DependencyModeRepair, wantDeps: []string{"Research topic"}},
+		{name: "repair fuzzy", ref: "Research the topic", mode: DependencyModeRepair, wantDeps: []string{"Research topic"}},
+		{name: "repair unknown", ref: "Interview experts", mode: DependencyModeRepair, wantDeps: []string{}},
+		{name: "reject normalized", ref: "research topic", mode: DependencyModeReject, wantDeps: []string{"Research topic"}},
+		{name: "reject fuzzy", ref: "Research the topic", mode: DependencyModeReject, wantKind: graph.IssueFuzzyMatch},
+		{name: "reject unknown", ref: "Interview experts", mode: DependencyModeReject, wantKind: graph.IssueUnknownDependency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, _, err := CheckDependencies(tasks(tt.ref), tt.mode)
+			if tt.wantKind != "" {
+				var depErr *DependencyError
+				if !errors.As(err, &depErr) || len(depErr.Issues) != 1 || depErr.Issues[0].Kind != tt.wantKind {
+					t.Fatalf("CheckDependencies() error = %v, want one %s issue", err, tt.wantKind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckDependencies() error = %v", err)
+			}
+			if !reflect.DeepEqual(out[1].DependsOn, tt.wantDeps) {
+				t.Fatalf("DependsOn = %v, want %v", out[1].DependsOn, tt.wantDeps)
+			}
+		})
+	}
+}
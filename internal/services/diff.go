@@ -0,0 +1,109 @@
+package services
+
+import (
+	"slices"
+	"strings"
+)
+
+// TaskChange describes a task present in both lists whose details differ.
+type TaskChange struct {
+	Task   string   `json:"task"`
+	Before Task     `json:"before"`
+	After  Task     `json:"after"`
+	Fields []string `json:"fields"`
+}
+
+type PlanDiff struct {
+	Added   []Task       `json:"added"`
+	Removed []Task       `json:"removed"`
+	Changed []TaskChange `json:"changed"`
+}
+
+// Empty reports whether the two lists describe the same tasks.
+func (d PlanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// MatchTasks pairs tasks of after with tasks of before by name, exactly first
+// and then ignoring case and surrounding whitespace. The result holds, for
+// each task in after, the index of its counterpart in before or -1.
+func MatchTasks(before, after []Task) []int {
+	match := make([]int, len(after))
+	used := make([]bool, len(before))
+	for i := range match {
+		match[i] = -1
+	}
+
+	pass := func(key func(string) string) {
+		index := make(map[string]int, len(before))
+		for j := len(before) - 1; j >= 0; j-- {
+			if !used[j] {
+				index[key(before[j].Task)] = j
+			}
+		}
+		for i, t := range after {
+			if match[i] >= 0 {
+				continue
+			}
+			if j, ok := index[key(t.Task)]; ok && !used[j] {
+				match[i], used[j] = j, true
+			}
+		}
+	}
+	pass(func(s string) string { return s })
+	pass(func(s string) string { return strings.ToLower(strings.TrimSpace(s)) })
+	return match
+}
+
+// DiffTasks reports the tasks added, removed and changed between two versions
+// of a plan.
+func DiffTasks(before, after []Task) PlanDiff {
+	return DiffMatchedTasks(before, after, MatchTasks(before, after))
+}
+
+// DiffMatchedTasks is DiffTasks with the pairing supplied by the caller, in
+// the form returned by MatchTasks. Callers that know task identities use it
+// to report renames as changes rather than as a removal and an addition.
+func DiffMatchedTasks(before, after []Task, match []int) PlanDiff {
+	diff := PlanDiff{Added: []Task{}, Removed: []Task{}, Changed: []TaskChange{}}
+	matched := make([]bool, len(before))
+
+	for i, t := range after {
+		j := match[i]
+		if j < 0 || matched[j] {
+			diff.Added = append(diff.Added, t)
+			continue
+		}
+		matched[j] = true
+		if fields := changedFields(before[j], t); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, TaskChange{Task: t.Task, Before: before[j], After: t, Fields: fields})
+		}
+	}
+	for j, t := range before {
+		if !matched[j] {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+	return diff
+}
+
+func changedFields(a, b Task) []string {
+	var fields []string
+	if a.Task != b.Task {
+		fields = append(fields, "task")
+	}
+	if a.DurationDays != b.DurationDays {
+		fields = append(fields, "duration_days")
+	}
+	if !sameNames(a.DependsOn, b.DependsOn) {
+		fields = append(fields, "depends_on")
+	}
+	return fields
+}
+
+func sameNames(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
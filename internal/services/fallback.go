@@ -0,0 +1,58 @@
+package services
+
+import "context"
+
+const fallbackModel = "template-v1"
+
+// FallbackGenerator returns a fixed five-step plan without calling any
+// provider. It is deterministic, which makes it suitable for offline runs and CI.
+type FallbackGenerator struct{}
+
+func (FallbackGenerator) Name() string  { return ProviderFallback }
+func (FallbackGenerator) Model() string { return fallbackModel }
+
+// GeneratePlan returns the template's seed tasks when it has any, and the
+// generic five-step plan otherwise.
+func (FallbackGenerator) GeneratePlan(_ context.Context, req PlanRequest) (*PlanResult, error) {
+	tasks := createFallbackPlan(req.Goal)
+	if req.Template != nil && len(req.Template.SeedTasks) > 0 {
+		tasks = req.Template.seedTasks()
+	}
+	return &PlanResult{
+		Tasks:    tasks,
+		Source:   SourceFallback,
+		Reason:   ReasonConfigured,
+		Provider: ProviderFallback,
+		Model:    fallbackModel,
+	}, nil
+}
+
+func createFallbackPlan(goal string) []Task {
+	return []Task{
+		{
+			Task:         "Research and understand the goal: " + goal,
+			DurationDays: 2,
+			DependsOn:    []string{},
+		},
+		{
+			Task:         "Create a detailed plan and timeline",
+			DurationDays: 1,
+			DependsOn:    []string{"Research and understand the goal: " + goal},
+		},
+		{
+			Task:         "Execute the first phase of the plan",
+			DurationDays: 5,
+			DependsOn:    []string{"Create a detailed plan and timeline"},
+		},
+		{
+			Task:         "Review progress and adjust approach",
+			DurationDays: 1,
+			DependsOn:    []string{"Execute the first phase of the plan"},
+		},
+		{
+			Task:         "Complete the goal: " + goal,
+			DurationDays: 7,
+			DependsOn:    []string{"Review progress and adjust approach"},
+		},
+	}
+}
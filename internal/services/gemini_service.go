@@ -1,159 +1,226 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strings"
-	"time"
+
+	"go.uber.org/zap"
 )
 
-type Task struct {
-	Task         string   `json:"task"`
-	DurationDays int      `json:"duration_days"`
-	DependsOn    []string `json:"depends_on"`
+const defaultGeminiModel = "gemini-2.5-flash-lite"
+
+type GeminiGenerator struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+func NewGeminiGenerator(client *http.Client, baseURL, apiKey, model string) *GeminiGenerator {
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	return &GeminiGenerator{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+func (g *GeminiGenerator) Name() string  { return ProviderGemini }
+func (g *GeminiGenerator) Model() string { return g.model }
+
+// taskResponseSchema constrains Gemini's output to a task array, so the
+// response text can be decoded directly.
+var taskResponseSchema = map[string]any{
+	"type": "ARRAY",
+	"items": map[string]any{
+		"type": "OBJECT",
+		"properties": map[string]any{
+			"task":          map[string]any{"type": "STRING"},
+			"duration_days": map[string]any{"type": "INTEGER"},
+			"depends_on": map[string]any{
+				"type":  "ARRAY",
+				"items": map[string]any{"type": "STRING"},
+			},
+		},
+		"required":         []string{"task", "duration_days", "depends_on"},
+		"propertyOrdering": []string{"task", "duration_days", "depends_on"},
+	},
 }
 
-func GeneratePlan(ctx context.Context, goal string) ([]Task, error) {
-	base := strings.TrimSuffix(os.Getenv("GEMINI_BASE_URL"), "/")
-	key := os.Getenv("GEMINI_API_KEY")
-	if base == "" || key == "" {
-		fmt.Printf("Gemini config missing - using fallback plan\n")
-		return createFallbackPlan(goal), nil
+// responseSchema applies the requested task count range to the schema.
+func responseSchema(opts GenerationOptions) map[string]any {
+	if opts.MinTasks == 0 && opts.MaxTasks == 0 {
+		return taskResponseSchema
+	}
+	schema := make(map[string]any, len(taskResponseSchema)+2)
+	for k, v := range taskResponseSchema {
+		schema[k] = v
+	}
+	if opts.MinTasks > 0 {
+		schema["minItems"] = opts.MinTasks
+	}
+	if opts.MaxTasks > 0 {
+		schema["maxItems"] = opts.MaxTasks
+	}
+	return schema
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// usage converts the response's usage metadata. Thinking tokens are billed
+// as output.
+func (r *geminiResponse) usage() TokenUsage {
+	if r.UsageMetadata == nil {
+		return TokenUsage{}
+	}
+	m := r.UsageMetadata
+	return TokenUsage{
+		PromptTokens:     m.PromptTokenCount,
+		CompletionTokens: m.CandidatesTokenCount + m.ThoughtsTokenCount,
+		TotalTokens:      m.TotalTokenCount,
+	}
+}
+
+// GeneratePlan asks Gemini for the task list. When req.OnTask is set the
+// response is streamed and each task is reported as soon as it is complete.
+func (g *GeminiGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	prompt, err := buildPrompt(req)
+	if err != nil {
+		return nil, err
 	}
 
 	payload := map[string]any{
 		"contents": []map[string]any{
 			{
 				"parts": []map[string]any{
-					{
-						"text": fmt.Sprintf(`Break down this goal into actionable tasks with suggested deadlines and dependencies.: "%s"
-Return ONLY a valid JSON array of tasks. Each task must have exactly these fields:
-- "task": string (description of the task)
-- "duration_days": number (estimated days to complete)
-- "depends_on": array of strings (names of prerequisite tasks, empty array if none)
-
-Example format:
-[
-  {"task": "Research topic", "duration_days": 2, "depends_on": []},
-  {"task": "Create outline", "duration_days": 1, "depends_on": ["Research topic"]}
-]
-
-Goal: %s`, goal, goal),
-					},
+					{"text": prompt},
 				},
 			},
 		},
 		"generationConfig": map[string]any{
-			"maxOutputTokens": 1000,
-			"temperature":     0.7,
+			"maxOutputTokens":  2048,
+			"temperature":      0.7,
+			"responseMimeType": "application/json",
+			"responseSchema":   responseSchema(req.Options),
 		},
 	}
-
-	body, _ := json.Marshal(payload)
-	url := fmt.Sprintf("%s/v1beta/models/gemini-2.5-flash-lite:generateContent?key=%s", base, key)
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	headers := map[string]string{"x-goog-api-key": g.apiKey}
+
+	var text string
+	var usage TokenUsage
+	if req.OnTask != nil {
+		text, usage, err = g.stream(ctx, headers, payload, req.OnTask)
+	} else {
+		text, usage, err = g.generate(ctx, headers, payload)
+	}
 	if err != nil {
-		fmt.Printf("Gemini API request failed: %v, using fallback plan\n", err)
-		return createFallbackPlan(goal), nil
+		return nil, &GenerationError{Err: err, Provider: ProviderGemini, Model: g.model, Usage: usage}
 	}
-	defer resp.Body.Close()
-
-	b, _ := io.ReadAll(resp.Body)
-
-	fmt.Printf("Gemini API Response Status: %d\n", resp.StatusCode)
-	fmt.Printf("Gemini API Response Body: %s\n", string(b))
+	zap.L().Debug("gemini generated text", zap.String("text", text))
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Gemini API error: status %d, using fallback plan\n", resp.StatusCode)
-		return createFallbackPlan(goal), nil
+	tasks, err := decodeTasks(text)
+	if err != nil {
+		return nil, &GenerationError{Err: err, Provider: ProviderGemini, Model: g.model, Usage: usage}
 	}
+	return &PlanResult{Tasks: tasks, Source: SourceLLM, Provider: ProviderGemini, Model: g.model, Usage: usage}, nil
+}
 
-	// Parse Gemini API response
-	var geminiResp struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
+func (g *GeminiGenerator) generate(ctx context.Context, headers map[string]string, payload any) (string, TokenUsage, error) {
+	var resp geminiResponse
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent", g.baseURL, g.model)
+	if err := postJSON(ctx, g.client, ProviderGemini, url, headers, payload, &resp); err != nil {
+		return "", TokenUsage{}, err
 	}
 
-	if err := json.Unmarshal(b, &geminiResp); err != nil {
-		fmt.Printf("Failed to parse Gemini response: %v, using fallback plan\n", err)
-		return createFallbackPlan(goal), nil
+	var text strings.Builder
+	finish, err := g.appendResponse(&text, &resp)
+	if err != nil {
+		return "", resp.usage(), err
 	}
-
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		fmt.Printf("No content generated by Gemini, using fallback plan\n")
-		return createFallbackPlan(goal), nil
+	if text.Len() == 0 {
+		return "", resp.usage(), fmt.Errorf("no content generated by gemini")
 	}
-
-	text := geminiResp.Candidates[0].Content.Parts[0].Text
-	fmt.Printf("Generated text: %s\n", text)
-
-	// Extract JSON array from the response
-	jsonStart := strings.Index(text, "[")
-	jsonEnd := strings.LastIndex(text, "]")
-	if jsonStart == -1 || jsonEnd == -1 || jsonEnd <= jsonStart {
-		fmt.Printf("No JSON array found in response, using fallback plan\n")
-		return createFallbackPlan(goal), nil
+	if finish == "MAX_TOKENS" {
+		return "", resp.usage(), documentError("response was truncated at the output token limit")
 	}
+	return text.String(), resp.usage(), nil
+}
 
-	arrText := text[jsonStart : jsonEnd+1]
-	var tasks []Task
-	if err := json.Unmarshal([]byte(arrText), &tasks); err != nil {
-		fmt.Printf("JSON parse error: %v, using fallback plan\n", err)
-		fmt.Printf("Attempted to parse: %s\n", arrText)
-		return createFallbackPlan(goal), nil
+// stream reads the response of streamGenerateContent, feeding the text of
+// each chunk to an incremental task parser. Usage is cumulative, so the
+// last chunk that reports it wins.
+func (g *GeminiGenerator) stream(ctx context.Context, headers map[string]string, payload any, onTask func(int, Task) error) (string, TokenUsage, error) {
+	parser := newTaskStreamParser(onTask)
+	var finish string
+	var usage TokenUsage
+	url := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse", g.baseURL, g.model)
+	err := postSSE(ctx, g.client, ProviderGemini, url, headers, payload, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("decode gemini stream chunk: %w", err)
+		}
+		var text strings.Builder
+		reason, err := g.appendResponse(&text, &chunk)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			finish = reason
+		}
+		if chunk.UsageMetadata != nil {
+			usage = chunk.usage()
+		}
+		return parser.Write(text.String())
+	})
+	if err != nil {
+		return "", usage, err
 	}
 
-	// Validate tasks
-	if len(tasks) == 0 {
-		fmt.Printf("No tasks generated, using fallback plan\n")
-		return createFallbackPlan(goal), nil
+	if parser.Text() == "" {
+		return "", usage, fmt.Errorf("no content generated by gemini")
 	}
-
-	fmt.Printf("Successfully generated %d tasks\n", len(tasks))
-	return tasks, nil
+	if finish == "MAX_TOKENS" {
+		return "", usage, documentError("response was truncated at the output token limit")
+	}
+	return parser.Text(), usage, nil
 }
 
-func createFallbackPlan(goal string) []Task {
-	return []Task{
-		{
-			Task:         "Research and understand the goal: " + goal,
-			DurationDays: 2,
-			DependsOn:    []string{},
-		},
-		{
-			Task:         "Create a detailed plan and timeline",
-			DurationDays: 1,
-			DependsOn:    []string{"Research and understand the goal: " + goal},
-		},
-		{
-			Task:         "Execute the first phase of the plan",
-			DurationDays: 5,
-			DependsOn:    []string{"Create a detailed plan and timeline"},
-		},
-		{
-			Task:         "Review progress and adjust approach",
-			DurationDays: 1,
-			DependsOn:    []string{"Execute the first phase of the plan"},
-		},
-		{
-			Task:         "Complete the goal: " + goal,
-			DurationDays: 7,
-			DependsOn:    []string{"Review progress and adjust approach"},
-		},
+// appendResponse writes the text of the first candidate to text and returns
+// its finish reason.
+func (g *GeminiGenerator) appendResponse(text *strings.Builder, resp *geminiResponse) (string, error) {
+	if reason := resp.PromptFeedback.BlockReason; reason != "" {
+		return "", fmt.Errorf("gemini blocked the prompt: %s", reason)
+	}
+	if len(resp.Candidates) == 0 {
+		return "", nil
+	}
+	candidate := resp.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
 	}
+	return candidate.FinishReason, nil
 }
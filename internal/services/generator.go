@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+)
+
+type Task struct {
+	Task         string   `json:"task" validate:"required,max=500"`
+	DurationDays int      `json:"duration_days" validate:"min=1,max=365"`
+	DependsOn    []string `json:"depends_on" validate:"dive,required"`
+}
+
+type PlanRequest struct {
+	Goal string
+	// Existing and Instruction turn the request into a refinement: the
+	// provider revises Existing as instructed instead of planning from
+	// scratch.
+	Existing    []Task
+	Instruction string
+	// Expand, when set, asks for the subtasks of this task of Existing
+	// instead of a whole plan.
+	Expand *Task
+	// Template and Variables replace the generic planning instructions with
+	// a goal-specific prompt.
+	Template  *PromptTemplate
+	Variables map[string]string
+	Options   GenerationOptions
+	// OnTask, when set, is called with each task as soon as the provider has
+	// produced it, before the plan is validated. Providers that cannot stream
+	// never call it. An error aborts generation.
+	OnTask func(index int, task Task) error
+	// CacheBypass skips the cache lookup; a fresh plan still replaces the
+	// cached one.
+	CacheBypass bool
+}
+
+type PlanSource string
+
+const (
+	SourceLLM      PlanSource = "llm"
+	SourceFallback PlanSource = "fallback"
+	SourceCache    PlanSource = "cache"
+	// SourceError marks a failed generation in the generations table; it is
+	// never the source of a plan.
+	SourceError PlanSource = "error"
+)
+
+// Reason codes explaining why a plan did not come from the provider.
+const (
+	ReasonConfigured          = "fallback_configured"
+	ReasonTimeout             = "timeout"
+	ReasonRateLimited         = "rate_limited"
+	ReasonProviderUnavailable = "provider_unavailable"
+	ReasonProviderRejected    = "provider_rejected"
+	ReasonRequestFailed       = "request_failed"
+	ReasonCircuitOpen         = "circuit_open"
+)
+
+type PlanResult struct {
+	Tasks    []Task
+	Source   PlanSource
+	Reason   string
+	Provider string
+	Model    string
+	// Usage and Latency cover every provider call made for the result,
+	// including a failed call that a fallback plan replaced; both are zero
+	// for cached plans.
+	Usage   TokenUsage
+	Latency time.Duration
+}
+
+// PlanGenerator turns a goal into a task list. Implementations talk to a
+// specific LLM provider; wrappers add fallback and dependency checks.
+type PlanGenerator interface {
+	Name() string
+	Model() string
+	GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error)
+}
+
+const (
+	ProviderGemini   = "gemini"
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+	ProviderFallback = "fallback"
+)
+
+// NewPlanGenerator builds the generator selected by cfg.LLMProvider. When
+// cfg.LLMFallbackEnabled is set, provider failures degrade to the
+// deterministic fallback plan. Failed requests are retried by the shared
+// transport, and a circuit breaker stops calling a provider that keeps
+// failing. Every result has its dependencies checked
+// according to cfg.DependencyMode, and checked plans are cached for
+// cfg.GenerationCacheTTLSeconds when that is positive.
+func NewPlanGenerator(cfg *config.Config) (PlanGenerator, error) {
+	client := &http.Client{
+		Timeout: time.Duration(cfg.LLMTimeoutSeconds) * time.Second,
+		Transport: newLLMTransport(RetryPolicy{
+			MaxRetries: cfg.LLMMaxRetries,
+			BaseDelay:  time.Duration(cfg.LLMRetryBaseMillis) * time.Millisecond,
+			MaxDelay:   time.Duration(cfg.LLMRetryMaxMillis) * time.Millisecond,
+		}),
+	}
+
+	var provider PlanGenerator
+	switch strings.ToLower(cfg.LLMProvider) {
+	case "", ProviderGemini:
+		if cfg.GeminiKey == "" || cfg.GeminiURL == "" {
+			return nil, fmt.Errorf("gemini provider requires GEMINI_API_KEY and GEMINI_BASE_URL")
+		}
+		provider = NewGeminiGenerator(client, cfg.GeminiURL, cfg.GeminiKey, cfg.LLMModel)
+	case ProviderOpenAI:
+		if cfg.OpenAIKey == "" {
+			return nil, fmt.Errorf("openai provider requires OPENAI_API_KEY")
+		}
+		provider = NewOpenAIGenerator(client, cfg.OpenAIURL, cfg.OpenAIKey, cfg.LLMModel)
+	case ProviderOllama:
+		provider = NewOllamaGenerator(client, cfg.OllamaURL, cfg.LLMModel)
+	case ProviderFallback:
+		provider = FallbackGenerator{}
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+
+	zap.L().Info("plan generator configured",
+		zap.String("provider", provider.Name()),
+		zap.String("model", provider.Model()),
+		zap.Bool("fallback_enabled", cfg.LLMFallbackEnabled),
+		zap.Int("cache_ttl_seconds", cfg.GenerationCacheTTLSeconds),
+		zap.Int("max_retries", cfg.LLMMaxRetries),
+		zap.Int("circuit_threshold", cfg.LLMCircuitThreshold),
+	)
+
+	prices, err := ParseModelPrices(cfg.LLMPrices)
+	if err != nil {
+		return nil, err
+	}
+	var gen PlanGenerator = &meteredGenerator{next: provider, prices: prices}
+	if provider.Name() != ProviderFallback {
+		if cfg.LLMCircuitThreshold > 0 {
+			breaker := newCircuitBreaker(cfg.LLMCircuitThreshold, time.Duration(cfg.LLMCircuitCooldownSeconds)*time.Second)
+			gen = &breakerGenerator{next: gen, breaker: breaker}
+		}
+		if cfg.LLMFallbackEnabled {
+			gen = &fallbackGenerator{next: gen}
+		}
+	}
+	gen = &dependencyCheckedGenerator{next: gen, mode: DependencyMode(cfg.DependencyMode)}
+	if cfg.GenerationCacheTTLSeconds > 0 && provider.Name() != ProviderFallback {
+		gen = &cachingGenerator{
+			next:  gen,
+			store: PostgresCacheStore{},
+			lru:   newLRUCache(cfg.GenerationCacheSize),
+			ttl:   time.Duration(cfg.GenerationCacheTTLSeconds) * time.Second,
+		}
+	}
+	return gen, nil
+}
+
+// FailureReason classifies a provider error into one of the reason codes.
+func FailureReason(err error) string {
+	var providerErr *ProviderError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return ReasonCircuitOpen
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	case errors.As(err, &providerErr) && providerErr.StatusCode == http.StatusTooManyRequests:
+		return ReasonRateLimited
+	case errors.As(err, &providerErr) && providerErr.StatusCode >= 500:
+		return ReasonProviderUnavailable
+	case errors.As(err, &providerErr):
+		return ReasonProviderRejected
+	default:
+		return ReasonRequestFailed
+	}
+}
+
+// fallbackGenerator substitutes the canned plan when the provider fails and
+// records why. Output that was received but does not match the task schema
+// is reported as a TaskValidationError instead.
+type fallbackGenerator struct {
+	next PlanGenerator
+}
+
+func (g *fallbackGenerator) Name() string          { return g.next.Name() }
+func (g *fallbackGenerator) Model() string         { return g.next.Model() }
+func (g *fallbackGenerator) Unwrap() PlanGenerator { return g.next }
+
+func (g *fallbackGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	res, err := g.next.GeneratePlan(ctx, req)
+	var validationErr *TaskValidationError
+	var templateErr *TemplateError
+	if errors.As(err, &validationErr) || errors.As(err, &templateErr) || errors.Is(ctx.Err(), context.Canceled) {
+		// Bad output and bad input are reported as such, and a caller that
+		// went away has no use for a substitute plan.
+		return nil, err
+	}
+	if err != nil {
+		reason := FailureReason(err)
+		zap.L().Warn("plan generation failed, using fallback plan",
+			zap.String("provider", g.next.Name()),
+			zap.String("reason", reason),
+			zap.Error(err),
+		)
+		fallback, _ := FallbackGenerator{}.GeneratePlan(ctx, req)
+		fallback.Reason = reason
+		fallback.Usage, fallback.Latency = generationCost(err)
+		return fallback, nil
+	}
+	return res, nil
+}
+
+type dependencyCheckedGenerator struct {
+	next PlanGenerator
+	mode DependencyMode
+}
+
+func (g *dependencyCheckedGenerator) Name() string          { return g.next.Name() }
+func (g *dependencyCheckedGenerator) Model() string         { return g.next.Model() }
+func (g *dependencyCheckedGenerator) Unwrap() PlanGenerator { return g.next }
+
+func (g *dependencyCheckedGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	res, err := g.next.GeneratePlan(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res.Tasks, _, err = CheckDependencies(res.Tasks, g.mode)
+	if err != nil {
+		return nil, &GenerationError{Err: err, Provider: res.Provider, Model: res.Model, Usage: res.Usage, Latency: res.Latency}
+	}
+	return res, nil
+}
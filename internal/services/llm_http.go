@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of a failed provider response is kept.
+const maxErrorBody = 2048
+
+// ProviderError is returned when an LLM provider answers with a non-2xx status.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s API error: status %d", e.Provider, e.StatusCode)
+}
+
+// postJSON sends payload as JSON and decodes a successful response into out.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, payload, out any) error {
+	resp, err := post(ctx, client, provider, url, headers, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", provider, err)
+	}
+	return nil
+}
+
+// postSSE sends payload as JSON and passes the data of each server-sent event
+// in the response to onData until the stream ends or onData fails.
+func postSSE(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, payload any, onData func([]byte) error) error {
+	resp, err := post(ctx, client, provider, url, headers, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				if err := onData(data.Bytes()); err != nil {
+					return err
+				}
+				data.Reset()
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(rest, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s stream: %w", provider, err)
+	}
+	if data.Len() > 0 {
+		return onData(data.Bytes())
+	}
+	return nil
+}
+
+func post(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s API request failed: %w", provider, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &ProviderError{Provider: provider, StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	return resp, nil
+}
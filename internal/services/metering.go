@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenUsage is what a generation cost. Providers fill in the token counts;
+// CostUSD is estimated from the configured model prices.
+type TokenUsage struct {
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	CostUSD          float64 `json:"costUsd"`
+}
+
+// Add returns the sum of u and other.
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CostUSD:          u.CostUSD + other.CostUSD,
+	}
+}
+
+// ModelPrice is the list price of a model in USD per million tokens.
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// defaultModelPrices covers the default models of each hosted provider.
+// Models without a price are recorded at no cost.
+var defaultModelPrices = map[string]ModelPrice{
+	"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10.00},
+	"gpt-4o-mini":           {Input: 0.15, Output: 0.60},
+	"gpt-4o":                {Input: 2.50, Output: 10.00},
+}
+
+// ParseModelPrices reads "model=input/output,..." prices in USD per million
+// tokens on top of the built-in price list.
+func ParseModelPrices(spec string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice, len(defaultModelPrices))
+	for model, price := range defaultModelPrices {
+		prices[model] = price
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(part, "=")
+		input, output, ok2 := strings.Cut(rates, "/")
+		in, err1 := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		out, err2 := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if !ok || !ok2 || err1 != nil || err2 != nil || in < 0 || out < 0 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("invalid price %q, want model=input/output", part)
+		}
+		prices[strings.TrimSpace(model)] = ModelPrice{Input: in, Output: out}
+	}
+	return prices, nil
+}
+
+// GenerationError is a failed generation together with what it cost. The
+// provider may have answered and charged for output that was then rejected,
+// for instance because it was truncated at the token limit or is not a valid
+// task list. Usage and Latency cover that call so it can still be recorded.
+type GenerationError struct {
+	Err      error
+	Provider string
+	Model    string
+	Usage    TokenUsage
+	Latency  time.Duration
+}
+
+func (e *GenerationError) Error() string { return e.Err.Error() }
+func (e *GenerationError) Unwrap() error { return e.Err }
+
+// generationCost returns the usage carried by err, or zero usage when err
+// does not come from a provider call.
+func generationCost(err error) (TokenUsage, time.Duration) {
+	var genErr *GenerationError
+	if errors.As(err, &genErr) {
+		return genErr.Usage, genErr.Latency
+	}
+	return TokenUsage{}, 0
+}
+
+// meteredGenerator times provider calls and prices their token usage,
+// including calls that failed. Its errors are always *GenerationError.
+type meteredGenerator struct {
+	next   PlanGenerator
+	prices map[string]ModelPrice
+}
+
+func (g *meteredGenerator) Name() string          { return g.next.Name() }
+func (g *meteredGenerator) Model() string         { return g.next.Model() }
+func (g *meteredGenerator) Unwrap() PlanGenerator { return g.next }
+
+func (g *meteredGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	start := time.Now()
+	res, err := g.next.GeneratePlan(ctx, req)
+	if err != nil {
+		var genErr *GenerationError
+		if !errors.As(err, &genErr) {
+			genErr = &GenerationError{Err: err, Provider: g.next.Name(), Model: g.next.Model()}
+			err = genErr
+		}
+		genErr.Latency = time.Since(start)
+		genErr.Usage.CostUSD = g.cost(genErr.Model, genErr.Usage)
+		return nil, err
+	}
+	res.Latency = time.Since(start)
+	res.Usage.CostUSD = g.cost(res.Model, res.Usage)
+	return res, nil
+}
+
+func (g *meteredGenerator) cost(model string, usage TokenUsage) float64 {
+	price := g.prices[model]
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubGenerator answers every request with a fixed result and error.
+type stubGenerator struct {
+	res *PlanResult
+	err error
+}
+
+func (g stubGenerator) Name() string  { return ProviderOpenAI }
+func (g stubGenerator) Model() string { return "gpt-4o-mini" }
+func (g stubGenerator) GeneratePlan(context.Context, PlanRequest) (*PlanResult, error) {
+	return g.res, g.err
+}
+
+func TestMeteredGenerator(t *testing.T) {
+	prices := map[string]ModelPrice{"gpt-4o-mini": {Input: 1, Output: 2}}
+	usage := TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}
+	const wantCost = 0.002
+	truncated := documentError("response was truncated at the output token limit")
+
+	tests := []struct {
+		name      string
+		next      stubGenerator
+		wantUsage TokenUsage
+		wantErr   error
+	}{
+		{
+			name:      "success",
+			next:      stubGenerator{res: &PlanResult{Source: SourceLLM, Model: "gpt-4o-mini", Usage: usage}},
+			wantUsage: TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500, CostUSD: wantCost},
+		},
+		{
+			name:      "rejected output keeps its usage",
+			next:      stubGenerator{err: &GenerationError{Err: truncated, Provider: ProviderOpenAI, Model: "gpt-4o-mini", Usage: usage}},
+			wantUsage: TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500, CostUSD: wantCost},
+			wantErr:   truncated,
+		},
+		{
+			name:    "transport failure costs nothing",
+			next:    stubGenerator{err: context.DeadlineExceeded},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &meteredGenerator{next: tt.next, prices: prices}
+			res, err := g.GeneratePlan(context.Background(), PlanRequest{})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("GeneratePlan() error = %v", err)
+				}
+				if res.Usage != tt.wantUsage {
+					t.Fatalf("Usage = %+v, want %+v", res.Usage, tt.wantUsage)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GeneratePlan() error = %v, want %v", err, tt.wantErr)
+			}
+			var genErr *GenerationError
+			if !errors.As(err, &genErr) {
+				t.Fatalf("GeneratePlan() error = %T, want *GenerationError", err)
+			}
+			if genErr.Usage != tt.wantUsage {
+				t.Fatalf("Usage = %+v, want %+v", genErr.Usage, tt.wantUsage)
+			}
+			if genErr.Provider != ProviderOpenAI || genErr.Model != "gpt-4o-mini" {
+				t.Fatalf("GenerationError names %s/%s", genErr.Provider, genErr.Model)
+			}
+		})
+	}
+}
+
+func TestFallbackKeepsFailedUsage(t *testing.T) {
+	usage := TokenUsage{PromptTokens: 10, TotalTokens: 10, CostUSD: 0.5}
+	next := stubGenerator{err: &GenerationError{Err: errors.New("no content generated by openai"), Usage: usage}}
+	res, err := (&fallbackGenerator{next: next}).GeneratePlan(context.Background(), PlanRequest{Goal: "Learn Go"})
+	if err != nil {
+		t.Fatalf("GeneratePlan() error = %v", err)
+	}
+	if res.Source != SourceFallback || res.Usage != usage {
+		t.Fatalf("GeneratePlan() = %s with usage %+v, want fallback with %+v", res.Source, res.Usage, usage)
+	}
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+)
+
+// Issuer presets adapt the OIDC flow to providers that need more than the
+// standard.
+const (
+	PresetAuth0    = "auth0"
+	PresetKeycloak = "keycloak"
+	PresetGeneric  = "generic"
+)
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type UserInfo struct {
+	Sub     string `json:"sub"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+}
+
+// TokenError is an error answer from an issuer's token endpoint, as opposed
+// to a failure to reach it. A rejected refresh token ends a session; an
+// unreachable issuer does not.
+type TokenError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("oidc error: %s - %s", e.Code, e.Description)
+}
+
+// AuthConnection is a login provider offered by /auth/login and the
+// upstream identity provider of the issuer that implements it.
+type AuthConnection struct {
+	Provider   string
+	Connection string
+}
+
+// oidcDiscovery is the part of an OpenID Provider configuration document
+// the flow uses.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+var oidcClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCProvider is a configured issuer. Its discovery document and signing
+// keys are fetched on first use and kept; a failed fetch is retried on the
+// next request.
+type OIDCProvider struct {
+	Name        string
+	Preset      string
+	Issuer      string
+	Connections []AuthConnection
+
+	clientID     string
+	clientSecret string
+	audience     string
+
+	mu   sync.Mutex
+	doc  *oidcDiscovery
+	jwks *keyfunc.JWKS
+}
+
+// OIDCProviders holds the configured issuers; the first is the default.
+type OIDCProviders struct {
+	list []*OIDCProvider
+}
+
+// NewOIDCProviders validates the issuers of cfg. Nothing is fetched yet.
+func NewOIDCProviders(cfg *config.Config) (*OIDCProviders, error) {
+	providers := &OIDCProviders{}
+	seen := make(map[string]bool)
+	for _, iss := range cfg.OIDCIssuers {
+		switch {
+		case iss.Name == "" || seen[iss.Name]:
+			return nil, fmt.Errorf("issuer name %q is empty or used twice", iss.Name)
+		case iss.Issuer == "" || iss.ClientID == "":
+			return nil, fmt.Errorf("issuer %s needs an issuer URL and a client ID", iss.Name)
+		case iss.Preset != PresetAuth0 && iss.Preset != PresetKeycloak && iss.Preset != PresetGeneric:
+			return nil, fmt.Errorf("issuer %s has unknown preset %q", iss.Name, iss.Preset)
+		}
+		if _, err := url.ParseRequestURI(iss.Issuer); err != nil {
+			return nil, fmt.Errorf("issuer %s: %w", iss.Name, err)
+		}
+		seen[iss.Name] = true
+		providers.list = append(providers.list, &OIDCProvider{
+			Name:         iss.Name,
+			Preset:       iss.Preset,
+			Issuer:       iss.Issuer,
+			Connections:  parseConnections(iss.Connections),
+			clientID:     iss.ClientID,
+			clientSecret: iss.ClientSecret,
+			audience:     iss.Audience,
+		})
+	}
+	return providers, nil
+}
+
+// Get returns the issuer called name, or the default one for an empty
+// name. It returns nil when there is no such issuer.
+func (ps *OIDCProviders) Get(name string) *OIDCProvider {
+	if len(ps.list) == 0 {
+		return nil
+	}
+	if name == "" {
+		return ps.list[0]
+	}
+	for _, p := range ps.list {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ByIssuer returns the issuer whose tokens carry iss.
+func (ps *OIDCProviders) ByIssuer(iss string) *OIDCProvider {
+	for _, p := range ps.list {
+		if sameIssuer(p.Issuer, iss) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Subject returns the user ID for subject sub of a token from p. Two issuers
+// may hand out the same subject, so subjects of every issuer but one are
+// prefixed with the issuer name, as "<name>|<sub>". The exception keeps
+// bare subjects, as before there were several issuers: the auth0 issuer when
+// it is configured, otherwise the default one.
+func (ps *OIDCProviders) Subject(p *OIDCProvider, sub string) string {
+	// "auth0" is the issuer the AUTH0_* variables configure.
+	bare := ps.Get("auth0")
+	if bare == nil || bare.Preset != PresetAuth0 {
+		bare = ps.Get("")
+	}
+	if p == bare {
+		return sub
+	}
+	return p.Name + "|" + sub
+}
+
+func (ps *OIDCProviders) Names() []string {
+	names := make([]string, len(ps.list))
+	for i, p := range ps.list {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Cleanup stops the background JWKS refreshes.
+func (ps *OIDCProviders) Cleanup() {
+	for _, p := range ps.list {
+		p.mu.Lock()
+		if p.jwks != nil {
+			p.jwks.EndBackground()
+			p.jwks = nil
+		}
+		p.mu.Unlock()
+	}
+}
+
+// ConnectionParam is the authorization request parameter that selects a
+// connection, or "" when the preset does not support connections.
+func (p *OIDCProvider) ConnectionParam() string {
+	switch p.Preset {
+	case PresetAuth0:
+		return "connection"
+	case PresetKeycloak:
+		return "kc_idp_hint"
+	}
+	return ""
+}
+
+// AuthorizeURL returns the authorization endpoint URL for params, with the
+// client ID added and, for Auth0, the audience, without which Auth0 issues
+// opaque access tokens.
+func (p *OIDCProvider) AuthorizeURL(ctx context.Context, params url.Values) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	params.Set("client_id", p.clientID)
+	if p.Preset == PresetAuth0 && p.audience != "" {
+		params.Set("audience", p.audience)
+	}
+	return appendQuery(doc.AuthorizationEndpoint, params), nil
+}
+
+// ExchangeCode redeems an authorization code issued for redirectURI. The
+// verifier is sent when the login used a PKCE challenge.
+func (p *OIDCProvider) ExchangeCode(ctx context.Context, code, verifier, redirectURI string) (*TokenResponse, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	return p.token(ctx, form)
+}
+
+// Refresh trades a refresh token for a new access token. With refresh
+// token rotation enabled at the issuer, the response carries a new refresh
+// token and the old one stops working.
+func (p *OIDCProvider) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	return p.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// Revoke revokes a refresh token, and with it the access tokens issued
+// from it. Issuers without a revocation endpoint are left alone.
+func (p *OIDCProvider) Revoke(ctx context.Context, refreshToken string) error {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := doc.RevocationEndpoint
+	if endpoint == "" && p.Preset == PresetAuth0 {
+		endpoint = strings.TrimSuffix(p.Issuer, "/") + "/oauth/revoke"
+	}
+	if endpoint == "" {
+		return nil
+	}
+	return p.post(ctx, endpoint, url.Values{
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+	}, nil)
+}
+
+func (p *OIDCProvider) UserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", doc.UserinfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get user info from %s", p.Name)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var userResp UserInfo
+	if err := json.Unmarshal(body, &userResp); err != nil {
+		return nil, err
+	}
+
+	return &userResp, nil
+}
+
+// LogoutURL returns where to send the browser to end its login at the
+// issuer, coming back to returnTo.
+func (p *OIDCProvider) LogoutURL(ctx context.Context, returnTo string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if doc.EndSessionEndpoint == "" && p.Preset == PresetAuth0 {
+		// Tenants without RP-initiated logout only have Auth0's own endpoint.
+		return appendQuery(strings.TrimSuffix(p.Issuer, "/")+"/v2/logout", url.Values{
+			"client_id": {p.clientID},
+			"returnTo":  {returnTo},
+		}), nil
+	}
+	if doc.EndSessionEndpoint == "" {
+		return "", fmt.Errorf("issuer %s has no end_session_endpoint", p.Name)
+	}
+	return appendQuery(doc.EndSessionEndpoint, url.Values{
+		"client_id":                {p.clientID},
+		"post_logout_redirect_uri": {returnTo},
+	}), nil
+}
+
+// Keyfunc returns the key lookup that verifies the issuer's token
+// signatures, from the JWKS named in its discovery document.
+func (p *OIDCProvider) Keyfunc(ctx context.Context) (jwt.Keyfunc, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	jwks := p.jwks
+	p.mu.Unlock()
+	if jwks != nil {
+		return jwks.Keyfunc, nil
+	}
+
+	// As with discovery, the keys are fetched unlocked and the first set
+	// stored wins; a set that lost the race stops its refreshes.
+	options := keyfunc.Options{
+		Ctx:             context.Background(),
+		RefreshInterval: time.Hour,
+		RefreshTimeout:  10 * time.Second,
+		// Keys rotated at the issuer are picked up when a token names one
+		// we have not seen.
+		RefreshUnknownKID: true,
+		RefreshRateLimit:  time.Minute,
+		RefreshErrorHandler: func(err error) {
+			zap.L().Error("JWKS refresh failed", zap.String("issuer", p.Name), zap.Error(err))
+		},
+	}
+	k, err := keyfunc.Get(doc.JWKSURI, options)
+	if err != nil {
+		zap.L().Error("Failed to initialize JWKS", zap.Error(err), zap.String("jwks_url", doc.JWKSURI))
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jwks != nil {
+		k.EndBackground()
+		return p.jwks.Keyfunc, nil
+	}
+	p.jwks = k
+	zap.L().Info("JWKS initialized successfully", zap.String("issuer", p.Name))
+	return k.Keyfunc, nil
+}
+
+// AcceptsAudience reports whether claims were issued for this API: aud
+// contains the configured audience or, without one, the client ID, which
+// some issuers put in azp instead.
+func (p *OIDCProvider) AcceptsAudience(claims jwt.MapClaims) bool {
+	if p.audience != "" {
+		return audienceContains(claims["aud"], p.audience)
+	}
+	azp, _ := claims["azp"].(string)
+	return azp == p.clientID || audienceContains(claims["aud"], p.clientID)
+}
+
+// discover fetches and keeps the issuer's discovery document.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	doc := p.doc
+	p.mu.Unlock()
+	if doc != nil {
+		return doc, nil
+	}
+
+	// The fetch runs unlocked so that a slow issuer holds up only the
+	// requests waiting for it. Concurrent first requests may each fetch; the
+	// first document stored wins.
+	doc, err := p.fetchDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.doc == nil {
+		p.doc = doc
+		zap.L().Info("OIDC discovery completed", zap.String("issuer", p.Name))
+	}
+	return p.doc, nil
+}
+
+func (p *OIDCProvider) fetchDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	discoveryURL := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	req, _ := http.NewRequestWithContext(ctx, "GET", discoveryURL, nil)
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery for %s returned %d", p.Name, resp.StatusCode)
+	}
+
+	var doc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	// The spec requires the document to name the issuer it was fetched
+	// for; anything else could hand out another issuer's keys.
+	if !sameIssuer(doc.Issuer, p.Issuer) {
+		return nil, fmt.Errorf("oidc discovery for %s names issuer %q", p.Name, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document lacks required endpoints")
+	}
+	return &doc, nil
+}
+
+func (p *OIDCProvider) token(ctx context.Context, form url.Values) (*TokenResponse, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var token TokenResponse
+	if err := p.post(ctx, doc.TokenEndpoint, form, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// post sends form with the client credentials to endpoint and decodes a
+// successful answer into out, when out is not nil.
+func (p *OIDCProvider) post(ctx context.Context, endpoint string, form url.Values, out any) error {
+	form.Set("client_id", p.clientID)
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, tokenErr)
+		return tokenErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// parseConnections parses "provider=connection,...".
+func parseConnections(spec string) []AuthConnection {
+	var conns []AuthConnection
+	for _, part := range strings.Split(spec, ",") {
+		provider, connection, ok := strings.Cut(strings.TrimSpace(part), "=")
+		provider, connection = strings.TrimSpace(provider), strings.TrimSpace(connection)
+		if !ok || provider == "" || connection == "" {
+			continue
+		}
+		conns = append(conns, AuthConnection{Provider: provider, Connection: connection})
+	}
+	return conns
+}
+
+// sameIssuer compares issuer identifiers, ignoring a trailing slash, which
+// Auth0 adds and most others do not.
+func sameIssuer(a, b string) bool {
+	return a != "" && strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// audienceContains reports whether the aud claim, a string or a list of
+// strings, contains audience.
+func audienceContains(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func appendQuery(endpoint string, params url.Values) string {
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + params.Encode()
+	}
+	return endpoint + "?" + params.Encode()
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+)
+
+func TestOIDCProvidersSubject(t *testing.T) {
+	auth0 := config.OIDCIssuer{Name: "auth0", Preset: PresetAuth0, Issuer: "https://tenant.auth0.com/", ClientID: "a"}
+	keycloak := config.OIDCIssuer{Name: "keycloak", Preset: PresetKeycloak, Issuer: "https://sso.example.com/realms/planner", ClientID: "k"}
+	okta := config.OIDCIssuer{Name: "okta", Preset: PresetGeneric, Issuer: "https://example.okta.com", ClientID: "o"}
+
+	tests := []struct {
+		name    string
+		issuers []config.OIDCIssuer
+		issuer  string
+		want    string
+	}{
+		{name: "auth0 default", issuers: []config.OIDCIssuer{auth0, keycloak}, issuer: "auth0", want: "google-oauth2|42"},
+		{name: "other issuer", issuers: []config.OIDCIssuer{auth0, keycloak}, issuer: "keycloak", want: "keycloak|google-oauth2|42"},
+		{name: "auth0 not default", issuers: []config.OIDCIssuer{keycloak, auth0}, issuer: "auth0", want: "google-oauth2|42"},
+		{name: "default when auth0 not default", issuers: []config.OIDCIssuer{keycloak, auth0}, issuer: "keycloak", want: "keycloak|google-oauth2|42"},
+		{name: "default without auth0", issuers: []config.OIDCIssuer{keycloak, okta}, issuer: "keycloak", want: "google-oauth2|42"},
+		{name: "other without auth0", issuers: []config.OIDCIssuer{keycloak, okta}, issuer: "okta", want: "okta|google-oauth2|42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers, err := NewOIDCProviders(&config.Config{OIDCIssuers: tt.issuers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := providers.Subject(providers.Get(tt.issuer), "google-oauth2|42"); got != tt.want {
+				t.Fatalf("Subject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderFetchesOutsideLock(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			started <- struct{}{}
+			<-release
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/authorize",
+				"token_endpoint":         srv.URL + "/token",
+				"jwks_uri":               srv.URL + "/jwks",
+			})
+		case "/jwks":
+			w.Write([]byte(`{"keys":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	providers, err := NewOIDCProviders(&config.Config{OIDCIssuers: []config.OIDCIssuer{
+		{Name: "test", Preset: PresetGeneric, Issuer: srv.URL, ClientID: "client"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := providers.Get("")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := provider.Keyfunc(context.Background())
+		done <- err
+	}()
+	<-started
+
+	// A slow issuer must not hold the provider's lock.
+	cleaned := make(chan struct{})
+	go func() {
+		providers.Cleanup()
+		close(cleaned)
+	}()
+	select {
+	case <-cleaned:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("Cleanup blocked behind a discovery fetch")
+	}
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Keyfunc() error = %v", err)
+	}
+	if _, err := provider.Keyfunc(context.Background()); err != nil {
+		t.Fatalf("second Keyfunc() error = %v", err)
+	}
+	providers.Cleanup()
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultOllamaURL   = "http://localhost:11434"
+	defaultOllamaModel = "llama3.1"
+)
+
+// OllamaGenerator uses a local Ollama server (or anything exposing the same
+// /api/generate endpoint), so plans can be generated without external APIs.
+type OllamaGenerator struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+func NewOllamaGenerator(client *http.Client, baseURL, model string) *OllamaGenerator {
+	if baseURL == "" {
+		baseURL = defaultOllamaURL
+	}
+	if model == "" {
+		model = defaultOllamaModel
+	}
+	return &OllamaGenerator{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+	}
+}
+
+func (g *OllamaGenerator) Name() string  { return ProviderOllama }
+func (g *OllamaGenerator) Model() string { return g.model }
+
+func (g *OllamaGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	prompt, err := buildPrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]any{
+		"model":  g.model,
+		"prompt": prompt,
+		"stream": false,
+		"options": map[string]any{
+			"temperature": 0.7,
+		},
+	}
+
+	var ollamaResp struct {
+		Response        string `json:"response"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+	if err := postJSON(ctx, g.client, ProviderOllama, g.baseURL+"/api/generate", nil, payload, &ollamaResp); err != nil {
+		return nil, err
+	}
+	usage := TokenUsage{
+		PromptTokens:     ollamaResp.PromptEvalCount,
+		CompletionTokens: ollamaResp.EvalCount,
+		TotalTokens:      ollamaResp.PromptEvalCount + ollamaResp.EvalCount,
+	}
+	tasks, err := parseTaskArray(ollamaResp.Response)
+	if err != nil {
+		return nil, &GenerationError{Err: err, Provider: ProviderOllama, Model: g.model, Usage: usage}
+	}
+	return &PlanResult{Tasks: tasks, Source: SourceLLM, Provider: ProviderOllama, Model: g.model, Usage: usage}, nil
+}
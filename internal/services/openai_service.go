@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultOpenAIURL   = "https://api.openai.com/v1"
+	defaultOpenAIModel = "gpt-4o-mini"
+)
+
+// OpenAIGenerator talks to any OpenAI-compatible chat completions endpoint.
+type OpenAIGenerator struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+func NewOpenAIGenerator(client *http.Client, baseURL, apiKey, model string) *OpenAIGenerator {
+	if baseURL == "" {
+		baseURL = defaultOpenAIURL
+	}
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	return &OpenAIGenerator{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+func (g *OpenAIGenerator) Name() string  { return ProviderOpenAI }
+func (g *OpenAIGenerator) Model() string { return g.model }
+
+func (g *OpenAIGenerator) GeneratePlan(ctx context.Context, req PlanRequest) (*PlanResult, error) {
+	prompt, err := buildPrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]any{
+		"model": g.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": "You are a project planner. Reply with JSON only."},
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0.7,
+		"max_tokens":  1000,
+	}
+
+	var openAIResp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + g.apiKey}
+	if err := postJSON(ctx, g.client, ProviderOpenAI, g.baseURL+"/chat/completions", headers, payload, &openAIResp); err != nil {
+		return nil, err
+	}
+
+	usage := TokenUsage{
+		PromptTokens:     openAIResp.Usage.PromptTokens,
+		CompletionTokens: openAIResp.Usage.CompletionTokens,
+		TotalTokens:      openAIResp.Usage.TotalTokens,
+	}
+	if len(openAIResp.Choices) == 0 {
+		return nil, &GenerationError{Err: fmt.Errorf("no content generated by openai"), Provider: ProviderOpenAI, Model: g.model, Usage: usage}
+	}
+	tasks, err := parseTaskArray(openAIResp.Choices[0].Message.Content)
+	if err != nil {
+		return nil, &GenerationError{Err: err, Provider: ProviderOpenAI, Model: g.model, Usage: usage}
+	}
+	return &PlanResult{Tasks: tasks, Source: SourceLLM, Provider: ProviderOpenAI, Model: g.model, Usage: usage}, nil
+}
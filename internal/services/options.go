@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/calendar"
+)
+
+// GenerationOptions are optional constraints on a generated plan.
+type GenerationOptions struct {
+	// Deadline is the date, YYYY-MM-DD, by which the plan should be finished.
+	Deadline    string  `json:"deadline,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	HoursPerDay float64 `json:"hours_per_day,omitempty" validate:"omitempty,gt=0,lte=24"`
+	TeamSize    int     `json:"team_size,omitempty" validate:"omitempty,min=1,max=100"`
+	MinTasks    int     `json:"min_tasks,omitempty" validate:"omitempty,min=1,max=100"`
+	MaxTasks    int     `json:"max_tasks,omitempty" validate:"omitempty,min=1,max=100,gtefield=MinTasks"`
+	DetailLevel string  `json:"detail_level,omitempty" validate:"omitempty,oneof=low medium high"`
+	// Language is a BCP 47 tag for the language of task names.
+	Language string `json:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	// DeadlineMode decides what happens when the plan overshoots Deadline:
+	// "warn" (the default) reports the late tasks, "compress" asks the
+	// generator to shorten the plan first.
+	DeadlineMode string `json:"deadline_mode,omitempty" validate:"omitempty,oneof=warn compress"`
+	// Today and Calendar anchor the deadline in the user's timezone and
+	// working days. They are set by the server, not by clients.
+	Today    time.Time          `json:"-"`
+	Calendar *calendar.Calendar `json:"-"`
+}
+
+var detailInstructions = map[string]string{
+	"low":    "Keep the plan high level: a few broad milestones rather than individual steps.",
+	"medium": "Use tasks of moderate size, each a meaningful piece of work.",
+	"high":   "Make the plan fine grained: concrete tasks that take no more than a few days each.",
+}
+
+// instructions renders the options as prompt lines, or "" when none is set.
+func (o GenerationOptions) instructions() string {
+	var lines []string
+	if o.Deadline != "" {
+		line := fmt.Sprintf("The whole plan must be finished by %s.", o.Deadline)
+		if deadline, err := time.Parse(calendar.DateLayout, o.Deadline); err == nil && !o.Today.IsZero() {
+			line = fmt.Sprintf("Today is %s. The whole plan must be finished by %s, which leaves %d calendar days; the longest chain of dependent tasks must fit in that time.",
+				o.Today.Format(calendar.DateLayout), o.Deadline, calendarDaysUntil(o.Today, deadline))
+		}
+		lines = append(lines, line)
+	}
+	if o.HoursPerDay > 0 {
+		lines = append(lines, fmt.Sprintf("Durations are in days of %g working hours each.", o.HoursPerDay))
+	}
+	switch {
+	case o.TeamSize == 1:
+		lines = append(lines, "One person does all the work, so tasks cannot run in parallel.")
+	case o.TeamSize > 1:
+		lines = append(lines, fmt.Sprintf("A team of %d people does the work; independent tasks can run in parallel, so avoid unnecessary dependencies.", o.TeamSize))
+	}
+	switch {
+	case o.MinTasks > 0 && o.MaxTasks > 0:
+		lines = append(lines, fmt.Sprintf("Produce between %d and %d tasks.", o.MinTasks, o.MaxTasks))
+	case o.MinTasks > 0:
+		lines = append(lines, fmt.Sprintf("Produce at least %d tasks.", o.MinTasks))
+	case o.MaxTasks > 0:
+		lines = append(lines, fmt.Sprintf("Produce at most %d tasks.", o.MaxTasks))
+	}
+	if s, ok := detailInstructions[o.DetailLevel]; ok {
+		lines = append(lines, s)
+	}
+	if o.Language != "" {
+		lines = append(lines, fmt.Sprintf("Write the task names and dependency names in the language with BCP 47 tag %q; keep the JSON field names in English.", o.Language))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Constraints:\n- " + strings.Join(lines, "\n- ") + "\n\n"
+}
+
+// calendarDaysUntil counts the dates from today through deadline inclusive.
+// Both are compared as plain dates, so neither the timezone Today is in nor a
+// daylight saving change in between shifts the count.
+func calendarDaysUntil(today, deadline time.Time) int {
+	y, m, d := today.Date()
+	from := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = deadline.Date()
+	to := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours()/24) + 1
+}
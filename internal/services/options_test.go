@@ -0,0 +1,84 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarDaysUntil(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+
+	tests := []struct {
+		name     string
+		today    time.Time
+		deadline string
+		want     int
+	}{
+		{name: "same day", today: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), deadline: "2026-03-02", want: 1},
+		{name: "next day", today: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), deadline: "2026-03-03", want: 2},
+		{name: "across month end", today: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC), deadline: "2026-03-02", want: 4},
+		{name: "west of utc", today: time.Date(2026, 3, 2, 0, 0, 0, 0, newYork), deadline: "2026-03-03", want: 2},
+		{name: "east of utc", today: time.Date(2026, 3, 2, 0, 0, 0, 0, auckland), deadline: "2026-03-03", want: 2},
+		{name: "spring forward", today: time.Date(2026, 3, 7, 0, 0, 0, 0, newYork), deadline: "2026-03-09", want: 3},
+		{name: "fall back", today: time.Date(2026, 10, 31, 0, 0, 0, 0, newYork), deadline: "2026-11-02", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline, err := time.Parse("2006-01-02", tt.deadline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := calendarDaysUntil(tt.today, deadline); got != tt.want {
+				t.Fatalf("calendarDaysUntil(%s, %s) = %d, want %d", tt.today, tt.deadline, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("tzdata unavailable:", err)
+	}
+
+	tests := []struct {
+		name string
+		opts GenerationOptions
+		want []string
+	}{
+		{name: "none", opts: GenerationOptions{}, want: nil},
+		{name: "deadline without today", opts: GenerationOptions{Deadline: "2026-03-10"}, want: []string{"finished by 2026-03-10."}},
+		{
+			name: "deadline in user timezone",
+			opts: GenerationOptions{Deadline: "2026-03-10", Today: time.Date(2026, 3, 1, 0, 0, 0, 0, tokyo)},
+			want: []string{"Today is 2026-03-01.", "leaves 10 calendar days"},
+		},
+		{name: "solo", opts: GenerationOptions{TeamSize: 1}, want: []string{"One person"}},
+		{name: "task range", opts: GenerationOptions{MinTasks: 3, MaxTasks: 8}, want: []string{"between 3 and 8 tasks"}},
+		{name: "detail", opts: GenerationOptions{DetailLevel: "high"}, want: []string{"fine grained"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.opts.instructions()
+			if tt.want == nil {
+				if got != "" {
+					t.Fatalf("instructions() = %q, want empty", got)
+				}
+				return
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("instructions() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/validation"
+)
+
+// maxGeneratedTasks caps the size of an accepted plan.
+const maxGeneratedTasks = 100
+
+// TaskProblem describes one way the generated output violates the task
+// schema. Index is -1 for problems with the document as a whole.
+type TaskProblem struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// TaskValidationError is returned when the provider answered but its output
+// is not a valid task list.
+type TaskValidationError struct {
+	Problems []TaskProblem
+}
+
+func (e *TaskValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid generated plan: " + e.Problems[0].Message
+	}
+	return fmt.Sprintf("invalid generated plan: %d problems", len(e.Problems))
+}
+
+func documentError(format string, args ...any) *TaskValidationError {
+	return &TaskValidationError{Problems: []TaskProblem{{Index: -1, Message: fmt.Sprintf(format, args...)}}}
+}
+
+const taskFormatInstructions = `Return ONLY a valid JSON array of tasks. Each task must have exactly these fields:
+- "task": string (description of the task)
+- "duration_days": number (estimated days to complete)
+- "depends_on": array of strings (names of prerequisite tasks, empty array if none)`
+
+func buildPrompt(req PlanRequest) (string, error) {
+	switch {
+	case req.Expand != nil:
+		return buildExpandPrompt(req), nil
+	case req.Instruction != "":
+		return buildRefinePrompt(req), nil
+	case req.Template != nil:
+		return buildTemplatePrompt(req)
+	}
+	return fmt.Sprintf(`Break down this goal into actionable tasks with suggested deadlines and dependencies.: "%s"
+%s%s
+
+Example format:
+[
+  {"task": "Research topic", "duration_days": 2, "depends_on": []},
+  {"task": "Create outline", "duration_days": 1, "depends_on": ["Research topic"]}
+]
+
+Goal: %s`, req.Goal, req.Options.instructions(), taskFormatInstructions, req.Goal), nil
+}
+
+// buildTemplatePrompt renders the template skeleton and appends the seed
+// tasks, duration guidance and output format.
+func buildTemplatePrompt(req PlanRequest) (string, error) {
+	body, err := req.Template.Render(req.Goal, req.Variables)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(body))
+	b.WriteString("\n\n")
+	if seeds := req.Template.seedTasks(); len(seeds) > 0 {
+		seedJSON, _ := json.MarshalIndent(seeds, "", "  ")
+		fmt.Fprintf(&b, "Include these tasks, keeping their names, and add the tasks needed around them:\n%s\n\n", seedJSON)
+	}
+	if d := req.Template.DefaultDurationDays; d > 0 {
+		fmt.Fprintf(&b, "Unless a task clearly needs a different length, estimate about %d days per task.\n", d)
+	}
+	b.WriteString(req.Options.instructions())
+	b.WriteString(taskFormatInstructions)
+	fmt.Fprintf(&b, "\n\nGoal: %s", req.Goal)
+	return b.String(), nil
+}
+
+// buildRefinePrompt asks for a revised version of an existing plan. Task
+// names are kept where possible so the result can be diffed against the
+// original.
+func buildRefinePrompt(req PlanRequest) string {
+	existing, _ := json.MarshalIndent(req.Existing, "", "  ")
+	return fmt.Sprintf(`Here is an existing task plan for the goal: "%s"
+
+%s
+
+Revise the plan according to this instruction: "%s"
+
+Keep the exact name of every task you do not rename, and keep tasks the instruction does not affect.
+Return the complete revised plan. %s`, req.Goal, existing, req.Instruction, taskFormatInstructions)
+}
+
+// buildExpandPrompt asks for the subtasks of one task, with dependencies
+// only between those subtasks.
+func buildExpandPrompt(req PlanRequest) string {
+	existing, _ := json.MarshalIndent(req.Existing, "", "  ")
+	instruction := ""
+	if req.Instruction != "" {
+		instruction = fmt.Sprintf("\nAdditional instruction: %q\n", req.Instruction)
+	}
+	return fmt.Sprintf(`Here is a task plan for the goal: "%s"
+
+%s
+
+Break the task %q (%d days) into 2 to 8 concrete subtasks.%s
+Return ONLY a valid JSON array of the subtasks. Each subtask must have exactly these fields:
+- "task": string (description of the subtask)
+- "duration_days": number (estimated days to complete)
+- "depends_on": array of strings (names of prerequisite subtasks from this array only, empty array if none)`,
+		req.Goal, existing, req.Expand.Task, req.Expand.DurationDays, instruction)
+}
+
+// decodeTasks parses output that must be exactly a JSON task array, as
+// produced by providers with structured output.
+func decodeTasks(text string) ([]Task, error) {
+	var tasks []Task
+	if err := json.Unmarshal([]byte(text), &tasks); err != nil {
+		return nil, documentError("response is not a JSON task array: %v", err)
+	}
+	return tasks, ValidateTasks(tasks)
+}
+
+// parseTaskArray extracts the JSON task array from free-form model output,
+// for providers that cannot be constrained to a schema.
+func parseTaskArray(text string) ([]Task, error) {
+	jsonStart := strings.Index(text, "[")
+	jsonEnd := strings.LastIndex(text, "]")
+	if jsonStart == -1 || jsonEnd == -1 || jsonEnd <= jsonStart {
+		return nil, documentError("no JSON array found in response")
+	}
+	return decodeTasks(text[jsonStart : jsonEnd+1])
+}
+
+// ValidateTasks checks a generated task list against the task schema.
+func ValidateTasks(tasks []Task) error {
+	if len(tasks) == 0 {
+		return documentError("no tasks generated")
+	}
+	if len(tasks) > maxGeneratedTasks {
+		return documentError("too many tasks: %d (max %d)", len(tasks), maxGeneratedTasks)
+	}
+
+	var problems []TaskProblem
+	for i := range tasks {
+		for _, fe := range validation.StructErrors(&tasks[i]) {
+			problems = append(problems, TaskProblem{Index: i, Field: fe.Field, Message: fe.Message})
+		}
+		if tasks[i].DependsOn == nil {
+			tasks[i].DependsOn = []string{}
+		}
+	}
+	if len(problems) > 0 {
+		return &TaskValidationError{Problems: problems}
+	}
+	return nil
+}
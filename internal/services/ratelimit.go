@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+// Built-in quota tiers. Deployments can add their own through a JWT claim.
+const (
+	TierAnonymous = "anonymous"
+	TierUser      = "user"
+)
+
+// Client identifies who a generation is charged to.
+type Client struct {
+	// Key is "user:<sub>" for signed-in users and "ip:<address>" otherwise.
+	Key  string
+	Tier string
+}
+
+// Usage describes a client's standing against both limits. A Limit of -1
+// means the tier has no daily quota.
+type Usage struct {
+	Tier            string    `json:"tier"`
+	Limit           int       `json:"limit"`
+	Used            int       `json:"used"`
+	Remaining       int       `json:"remaining"`
+	ResetsAt        time.Time `json:"resetsAt"`
+	WindowLimit     int       `json:"windowLimit"`
+	WindowSeconds   int       `json:"windowSeconds"`
+	WindowRemaining int       `json:"windowRemaining"`
+	WindowResetsAt  time.Time `json:"windowResetsAt"`
+}
+
+// RateLimitError reports a rejected generation. Kind is "rate_limited" for
+// the short window and "quota_exceeded" for the daily quota.
+type RateLimitError struct {
+	Kind       string
+	RetryAfter time.Duration
+	Usage      Usage
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Kind, e.RetryAfter)
+}
+
+// ParseQuotas parses "tier=count,..." into daily generation quotas.
+func ParseQuotas(spec string) (map[string]int, error) {
+	quotas := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tier, count, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || n < 0 || strings.TrimSpace(tier) == "" {
+			return nil, fmt.Errorf("invalid quota %q, want tier=count", part)
+		}
+		quotas[strings.TrimSpace(tier)] = n
+	}
+	return quotas, nil
+}
+
+// RateLimiter enforces a short fixed window per client, kept in memory, and
+// a daily generation quota per tier, counted in Postgres so that it holds
+// across restarts and instances. Days are UTC.
+type RateLimiter struct {
+	requests int
+	period   time.Duration
+	quotas   map[string]int
+	store    quotaStore
+
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter allows requests generations per period and the daily
+// quotas per tier. A non-positive requests disables the window; tiers
+// missing from quotas are unlimited.
+func NewRateLimiter(requests int, period time.Duration, quotas map[string]int) *RateLimiter {
+	if period <= 0 {
+		period = time.Second
+	}
+	requests = max(requests, 0)
+	return &RateLimiter{requests: requests, period: period, quotas: quotas, store: pgQuotaStore{}, windows: make(map[string]*rateWindow)}
+}
+
+// Take charges one generation to client, or returns a *RateLimitError when
+// either limit is exhausted.
+func (l *RateLimiter) Take(ctx context.Context, client Client) (Usage, error) {
+	now := time.Now()
+	usage := l.windowUsage(client.Key, now, true)
+	if usage.WindowLimit > 0 && usage.WindowRemaining < 0 {
+		usage.WindowRemaining = 0
+		if err := l.fillQuota(ctx, client, &usage, now, false); err != nil {
+			return usage, err
+		}
+		return usage, &RateLimitError{Kind: "rate_limited", RetryAfter: usage.WindowResetsAt.Sub(now), Usage: usage}
+	}
+	if err := l.fillQuota(ctx, client, &usage, now, true); err != nil {
+		return usage, err
+	}
+	if usage.Limit >= 0 && usage.Remaining < 0 {
+		// The generation is refused, so it must not use up the window either.
+		if usage.WindowLimit > 0 {
+			l.releaseWindow(client.Key, now)
+			usage.WindowRemaining++
+		}
+		usage.Remaining = 0
+		return usage, &RateLimitError{Kind: "quota_exceeded", RetryAfter: usage.ResetsAt.Sub(now), Usage: usage}
+	}
+	return usage, nil
+}
+
+// Peek reports client's usage without charging anything.
+func (l *RateLimiter) Peek(ctx context.Context, client Client) (Usage, error) {
+	now := time.Now()
+	usage := l.windowUsage(client.Key, now, false)
+	err := l.fillQuota(ctx, client, &usage, now, false)
+	return usage, err
+}
+
+// Refund gives back a generation charged by a Take that returned at
+// takenAt, for requests that ended up not calling the provider: rejected
+// requests and cache hits.
+func (l *RateLimiter) Refund(ctx context.Context, client Client, takenAt time.Time) error {
+	l.releaseWindow(client.Key, takenAt)
+	return l.store.refund(ctx, client.Key, takenAt.UTC().Truncate(24*time.Hour))
+}
+
+// releaseWindow gives back a window slot taken at takenAt. A window that has
+// rolled over since then no longer holds the charge.
+func (l *RateLimiter) releaseWindow(key string, takenAt time.Time) {
+	if l.requests <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w := l.windows[key]; w != nil && !takenAt.Before(w.start) && w.count > 0 {
+		w.count--
+	}
+}
+
+// windowUsage counts a request against key's window when take is set. The
+// remaining count goes negative when the request is over the limit.
+func (l *RateLimiter) windowUsage(key string, now time.Time, take bool) Usage {
+	usage := Usage{WindowLimit: l.requests, WindowSeconds: int(l.period / time.Second), WindowRemaining: -1}
+	if l.requests <= 0 {
+		return usage
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.period {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= l.period {
+		w = &rateWindow{start: now}
+		if take {
+			l.windows[key] = w
+		}
+	}
+	if take {
+		w.count++
+	}
+	usage.WindowRemaining = l.requests - w.count
+	usage.WindowResetsAt = w.start.Add(l.period)
+	return usage
+}
+
+// fillQuota sets the daily quota fields of usage, incrementing today's count
+// when take is set and the quota is not yet exhausted. Remaining goes
+// negative when a taken generation is over the quota.
+func (l *RateLimiter) fillQuota(ctx context.Context, client Client, usage *Usage, now time.Time, take bool) error {
+	day := now.UTC().Truncate(24 * time.Hour)
+	usage.Tier = client.Tier
+	usage.ResetsAt = day.Add(24 * time.Hour)
+
+	limit, limited := l.quotas[client.Tier]
+	if !limited {
+		limit = -1
+	}
+
+	var used int
+	var err error
+	if take {
+		var counted bool
+		used, counted, err = l.store.count(ctx, client.Key, day, limit)
+		if err == nil && !counted {
+			used, err = l.store.used(ctx, client.Key, day)
+			used++
+		}
+	} else {
+		used, err = l.store.used(ctx, client.Key, day)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !limited {
+		usage.Limit, usage.Used, usage.Remaining = -1, used, -1
+		return nil
+	}
+	usage.Limit = limit
+	usage.Used = min(used, limit)
+	usage.Remaining = limit - used
+	return nil
+}
+
+// quotaStore keeps the daily generation counts.
+type quotaStore interface {
+	// count increments key's count for day unless it has reached limit; a
+	// negative limit never blocks. It returns the new count and whether the
+	// generation was counted.
+	count(ctx context.Context, key string, day time.Time, limit int) (int, bool, error)
+	used(ctx context.Context, key string, day time.Time) (int, error)
+	refund(ctx context.Context, key string, day time.Time) error
+}
+
+// pgQuotaStore counts generations in the generation_usage table.
+type pgQuotaStore struct{}
+
+func (pgQuotaStore) count(ctx context.Context, key string, day time.Time, limit int) (int, bool, error) {
+	if limit == 0 {
+		return 0, false, nil
+	}
+	var count int
+	err := db.Pool.QueryRow(ctx,
+		`INSERT INTO generation_usage (subject, day, count) VALUES ($1, $2::date, 1)
+		 ON CONFLICT (subject, day) DO UPDATE SET count = generation_usage.count + 1
+		 WHERE $3::integer < 0 OR generation_usage.count < $3::integer
+		 RETURNING count`,
+		key, day.Format("2006-01-02"), limit,
+	).Scan(&count)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+func (pgQuotaStore) used(ctx context.Context, key string, day time.Time) (int, error) {
+	var count int
+	err := db.Pool.QueryRow(ctx,
+		"SELECT COALESCE((SELECT count FROM generation_usage WHERE subject=$1 AND day=$2::date), 0)",
+		key, day.Format("2006-01-02"),
+	).Scan(&count)
+	return count, err
+}
+
+func (pgQuotaStore) refund(ctx context.Context, key string, day time.Time) error {
+	_, err := db.Pool.Exec(ctx,
+		"UPDATE generation_usage SET count = count - 1 WHERE subject=$1 AND day=$2::date AND count > 0",
+		key, day.Format("2006-01-02"),
+	)
+	return err
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memQuotaStore keeps daily counts in memory.
+type memQuotaStore map[string]int
+
+func (s memQuotaStore) count(_ context.Context, key string, day time.Time, limit int) (int, bool, error) {
+	k := key + day.Format(time.DateOnly)
+	if limit >= 0 && s[k] >= limit {
+		return 0, false, nil
+	}
+	s[k]++
+	return s[k], true, nil
+}
+
+func (s memQuotaStore) used(_ context.Context, key string, day time.Time) (int, error) {
+	return s[key+day.Format(time.DateOnly)], nil
+}
+
+func (s memQuotaStore) refund(_ context.Context, key string, day time.Time) error {
+	if k := key + day.Format(time.DateOnly); s[k] > 0 {
+		s[k]--
+	}
+	return nil
+}
+
+func newTestLimiter(requests int, quotas map[string]int) *RateLimiter {
+	l := NewRateLimiter(requests, time.Hour, quotas)
+	l.store = memQuotaStore{}
+	return l
+}
+
+// limitKind returns the Kind of a *RateLimitError, or "" for nil.
+func limitKind(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Take() error = %v, want *RateLimitError", err)
+	}
+	return limitErr.Kind
+}
+
+func TestRateLimiterTake(t *testing.T) {
+	anon := Client{Key: "ip:1.2.3.4", Tier: TierAnonymous}
+	user := Client{Key: "user:a", Tier: TierUser}
+	tests := []struct {
+		name     string
+		requests int
+		quotas   map[string]int
+		client   Client
+		// want is the outcome of each Take in turn: "" for allowed.
+		want []string
+	}{
+		{name: "window", requests: 2, client: user, want: []string{"", "", "rate_limited", "rate_limited"}},
+		{name: "quota", requests: 5, quotas: map[string]int{TierUser: 2}, client: user, want: []string{"", "", "quota_exceeded", "quota_exceeded"}},
+		// Refused generations keep giving their window slot back, so the
+		// caller keeps hearing about the quota rather than the window.
+		{name: "quota inside window", requests: 3, quotas: map[string]int{TierUser: 1}, client: user, want: []string{"", "quota_exceeded", "quota_exceeded", "quota_exceeded", "quota_exceeded"}},
+		{name: "zero quota", requests: 5, quotas: map[string]int{TierAnonymous: 0}, client: anon, want: []string{"quota_exceeded", "quota_exceeded"}},
+		{name: "unlimited tier", quotas: map[string]int{TierAnonymous: 0}, client: user, want: []string{"", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(tt.requests, tt.quotas)
+			for i, want := range tt.want {
+				_, err := l.Take(context.Background(), tt.client)
+				if got := limitKind(t, err); got != want {
+					t.Fatalf("Take() #%d = %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterQuotaUsage(t *testing.T) {
+	l := newTestLimiter(3, map[string]int{TierUser: 1})
+	user := Client{Key: "user:a", Tier: TierUser}
+	if _, err := l.Take(context.Background(), user); err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+
+	usage, err := l.Take(context.Background(), user)
+	if limitKind(t, err) != "quota_exceeded" {
+		t.Fatalf("Take() error = %v, want quota_exceeded", err)
+	}
+	want := Usage{Tier: TierUser, Limit: 1, Used: 1, Remaining: 0, WindowLimit: 3, WindowSeconds: 3600, WindowRemaining: 2}
+	usage.ResetsAt, usage.WindowResetsAt = time.Time{}, time.Time{}
+	if usage != want {
+		t.Fatalf("Take() usage = %+v, want %+v", usage, want)
+	}
+
+	peek, err := l.Peek(context.Background(), user)
+	if err != nil || peek.Used != 1 || peek.WindowRemaining != 2 {
+		t.Fatalf("Peek() = %+v, %v, want 1 used and 2 left in the window", peek, err)
+	}
+}
+
+func TestRateLimiterRefund(t *testing.T) {
+	l := newTestLimiter(1, map[string]int{TierUser: 1})
+	user := Client{Key: "user:a", Tier: TierUser}
+
+	if _, err := l.Take(context.Background(), user); err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	takenAt := time.Now()
+	if err := l.Refund(context.Background(), user, takenAt); err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	usage, err := l.Take(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Take() after Refund() error = %v", err)
+	}
+	if usage.Used != 1 || usage.WindowRemaining != 0 {
+		t.Fatalf("Take() after Refund() usage = %+v, want 1 used and the window full", usage)
+	}
+
+	// A refund for a window that has since rolled over leaves the new one
+	// alone.
+	l = newTestLimiter(1, nil)
+	l.Take(context.Background(), user)
+	takenAt = time.Now()
+	l.mu.Lock()
+	l.windows[user.Key].start = takenAt.Add(-2 * time.Hour)
+	l.mu.Unlock()
+	if _, err := l.Take(context.Background(), user); err != nil {
+		t.Fatalf("Take() in a new window error = %v", err)
+	}
+	l.Refund(context.Background(), user, takenAt)
+	if _, err := l.Take(context.Background(), user); limitKind(t, err) != "rate_limited" {
+		t.Fatalf("Take() after a stale refund = %v, want rate_limited", err)
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	got, err := ParseQuotas(" anonymous=5, user = 50 ,,pro=0")
+	if err != nil {
+		t.Fatalf("ParseQuotas() error = %v", err)
+	}
+	if len(got) != 3 || got[TierAnonymous] != 5 || got[TierUser] != 50 || got["pro"] != 0 {
+		t.Fatalf("ParseQuotas() = %v", got)
+	}
+	for _, spec := range []string{"user", "user=-1", "=3", "user=x"} {
+		if _, err := ParseQuotas(spec); err == nil {
+			t.Errorf("ParseQuotas(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetryPolicy controls how often a failed provider request is repeated.
+type RetryPolicy struct {
+	// MaxRetries is the number of attempts after the first one.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry; each retry doubles it
+	// up to MaxDelay. A Retry-After longer than MaxDelay is not waited for.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// newLLMTransport returns the transport shared by every provider client:
+// connections are kept alive between plans and requests answered with 429
+// or 5xx, or that fail to connect, are retried according to policy.
+func newLLMTransport(policy RetryPolicy) http.RoundTripper {
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if policy.MaxRetries <= 0 {
+		return base
+	}
+	return &retryTransport{next: base, policy: policy}
+}
+
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+// RoundTrip sends a fresh clone of req for every retry, since a RoundTripper
+// must not modify the request it was given. A request whose body cannot be
+// rewound is sent only once.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(attemptReq)
+		if attempt >= t.policy.MaxRetries || !rewindable || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				if after > t.policy.MaxDelay {
+					return resp, nil
+				}
+				delay = after
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+		attemptReq = req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			attemptReq.Body = body
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		zap.L().Warn("retrying LLM request",
+			zap.String("host", req.URL.Host),
+			zap.Int("attempt", attempt+1),
+			zap.Int("status", status),
+			zap.Duration("delay", delay),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff is the delay before retry attempt+1: exponential with full jitter.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.BaseDelay << attempt
+	if d <= 0 || d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers each request with the next status in its
+// script and records the bodies it was sent.
+type scriptedTransport struct {
+	statuses   []int
+	retryAfter string
+	requests   []*http.Request
+	bodies     []string
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, req)
+	body := ""
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}
+	s.bodies = append(s.bodies, body)
+	status := s.statuses[min(len(s.requests), len(s.statuses))-1]
+	resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+	if s.retryAfter != "" {
+		resp.Header.Set("Retry-After", s.retryAfter)
+	}
+	return resp, nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	fast := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		statuses     []int
+		retryAfter   string
+		noGetBody    bool
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "success", policy: fast, statuses: []int{200}, wantStatus: 200, wantAttempts: 1},
+		{name: "retries server errors", policy: fast, statuses: []int{503, 502, 200}, wantStatus: 200, wantAttempts: 3},
+		{name: "gives up after max retries", policy: fast, statuses: []int{503}, wantStatus: 503, wantAttempts: 3},
+		{name: "client error is final", policy: fast, statuses: []int{400, 200}, wantStatus: 400, wantAttempts: 1},
+		{name: "retries rate limits", policy: fast, statuses: []int{429, 200}, wantStatus: 200, wantAttempts: 2},
+		{
+			// A zero Retry-After is honored instead of the hour of backoff.
+			name:         "honors Retry-After",
+			policy:       RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour, MaxDelay: time.Hour},
+			statuses:     []int{429, 200},
+			retryAfter:   "0",
+			wantStatus:   200,
+			wantAttempts: 2,
+		},
+		{
+			name:         "Retry-After beyond MaxDelay",
+			policy:       fast,
+			statuses:     []int{429, 200},
+			retryAfter:   "60",
+			wantStatus:   429,
+			wantAttempts: 1,
+		},
+		{name: "body cannot be rewound", policy: fast, statuses: []int{503, 200}, noGetBody: true, wantStatus: 503, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &scriptedTransport{statuses: tt.statuses, retryAfter: tt.retryAfter}
+			rt := &retryTransport{next: next, policy: tt.policy}
+			req, err := http.NewRequest(http.MethodPost, "http://llm.test/v1", strings.NewReader(`{"goal":"x"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.noGetBody {
+				req.GetBody = nil
+			}
+			body := req.Body
+
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || len(next.requests) != tt.wantAttempts {
+				t.Fatalf("RoundTrip() = %d after %d attempts, want %d after %d", resp.StatusCode, len(next.requests), tt.wantStatus, tt.wantAttempts)
+			}
+			for i, got := range next.bodies {
+				if got != `{"goal":"x"}` {
+					t.Fatalf("attempt %d sent body %q", i+1, got)
+				}
+			}
+			// Retries go out as clones; the caller's request is left alone.
+			if req.Body != body {
+				t.Fatal("RoundTrip() replaced the request body")
+			}
+			for i, sent := range next.requests[1:] {
+				if sent == req {
+					t.Fatalf("retry %d reused the original request", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	rt := &retryTransport{policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+	for attempt, limit := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second, time.Second} {
+		for range 50 {
+			if d := rt.backoff(attempt); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %v, want in (0, %v]", attempt, d, limit)
+			}
+		}
+	}
+	// The shift overflowing does not escape MaxDelay.
+	if d := rt.backoff(70); d <= 0 || d > time.Second {
+		t.Fatalf("backoff(70) = %v, want in (0, 1s]", d)
+	}
+	if d := (&retryTransport{}).backoff(0); d != 0 {
+		t.Fatalf("backoff without delays = %v, want 0", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "0", want: 0, wantOK: true},
+		{value: "3", want: 3 * time.Second, wantOK: true},
+		{value: "-1", wantOK: false},
+		{value: "soon", wantOK: false},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), want: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(future); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("retryAfter(%q) = %v, %v, want about an hour", future, got, ok)
+	}
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/config"
+	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
+)
+
+const (
+	SessionCookieName = "session"
+	// refreshMargin is how long before its expiry a session's access token
+	// is refreshed, so that it does not expire while a request is handled.
+	refreshMargin = time.Minute
+	// rotationGrace is how long the cookie value replaced by a rotation is
+	// still accepted, for requests that were already in flight. Presenting
+	// it later means the cookie was copied, and ends the session.
+	rotationGrace = time.Minute
+)
+
+var (
+	// ErrInvalidSession is returned for unknown, expired and revoked
+	// sessions, and for sessions whose refresh token the issuer rejected.
+	ErrInvalidSession = errors.New("invalid session")
+	// ErrSessionReused is returned when a rotated-out cookie value is
+	// presented after the grace period. The session is revoked.
+	ErrSessionReused = errors.New("session cookie reused")
+)
+
+// Session is a server-side login: the browser holds only an opaque cookie,
+// and the issuer's tokens stay encrypted in Postgres.
+type Session struct {
+	ID         string    `json:"id"`
+	Issuer     string    `json:"issuer"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// SessionToken is the access token of an authenticated session request.
+// Cookie is set when the session was rotated and the browser must be sent
+// the new cookie value.
+type SessionToken struct {
+	SessionID   string
+	AccessToken string
+	Cookie      string
+	ExpiresAt   time.Time
+}
+
+// SessionStore keeps sessions in Postgres. Cookie values are
+// "<session id>.<secret>"; only a hash of the secret is stored. Each
+// refresh of the access token also rotates the secret and extends the
+// session by its TTL.
+type SessionStore struct {
+	cfg       *config.Config
+	providers *OIDCProviders
+	ttl       time.Duration
+}
+
+// NewSessionStore returns a store whose sessions last SESSION_TTL_DAYS
+// since their last refresh. A non-positive TTL disables sessions.
+func NewSessionStore(cfg *config.Config, providers *OIDCProviders) *SessionStore {
+	return &SessionStore{cfg: cfg, providers: providers, ttl: time.Duration(cfg.SessionTTLDays) * 24 * time.Hour}
+}
+
+func (s *SessionStore) Enabled() bool {
+	return s != nil && s.ttl > 0
+}
+
+// Create starts a session for userID holding token from issuer, and
+// returns it with the cookie value for the browser.
+func (s *SessionStore) Create(ctx context.Context, userID, issuer string, token *TokenResponse, userAgent, ip string) (*Session, string, error) {
+	accessToken, err := SealToken(s.cfg, token.AccessToken)
+	if err != nil {
+		return nil, "", err
+	}
+	var refreshToken []byte
+	if token.RefreshToken != "" {
+		if refreshToken, err = SealToken(s.cfg, token.RefreshToken); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if _, err := db.Pool.Exec(ctx, "DELETE FROM sessions WHERE expires_at <= now()"); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	sess := &Session{
+		ID:         sessionSecret(16),
+		Issuer:     issuer,
+		UserAgent:  truncate(userAgent, 512),
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+		Current:    true,
+	}
+	secret := sessionSecret(32)
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO sessions (id, user_id, issuer, token_hash, access_token, access_expires_at, refresh_token, user_agent, ip, created_at, last_used_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10, $11)`,
+		sess.ID, userID, issuer, hashSessionSecret(secret), accessToken, accessExpiry(now, token), refreshToken,
+		sess.UserAgent, sess.IP, now, sess.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return sess, sess.ID + "." + secret, nil
+}
+
+// AccessToken returns the access token of the session identified by
+// cookie, refreshing it at the issuer when it is about to expire. It
+// returns ErrInvalidSession or ErrSessionReused when the cookie does not
+// authenticate.
+func (s *SessionStore) AccessToken(ctx context.Context, cookie string) (*SessionToken, error) {
+	id, secret, ok := strings.Cut(cookie, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrInvalidSession
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The row lock serialises refreshes of one session across requests and
+	// instances, so a rotated refresh token is never used twice.
+	var issuer, tokenHash string
+	var previousHash *string
+	var rotatedAt *time.Time
+	var sealedAccess, sealedRefresh []byte
+	var accessExpiresAt, expiresAt time.Time
+	err = tx.QueryRow(ctx,
+		`SELECT issuer, token_hash, previous_token_hash, rotated_at, access_token, access_expires_at, refresh_token, expires_at
+		 FROM sessions WHERE id=$1 FOR UPDATE`,
+		id,
+	).Scan(&issuer, &tokenHash, &previousHash, &rotatedAt, &sealedAccess, &accessExpiresAt, &sealedRefresh, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(expiresAt) {
+		return nil, ErrInvalidSession
+	}
+	hash := hashSessionSecret(secret)
+	current := subtle.ConstantTimeCompare([]byte(hash), []byte(tokenHash)) == 1
+	if !current {
+		if previousHash == nil || subtle.ConstantTimeCompare([]byte(hash), []byte(*previousHash)) != 1 {
+			return nil, ErrInvalidSession
+		}
+		if rotatedAt == nil || now.Sub(*rotatedAt) > rotationGrace {
+			if _, err := tx.Exec(ctx, "DELETE FROM sessions WHERE id=$1", id); err != nil {
+				return nil, err
+			}
+			if err := tx.Commit(ctx); err != nil {
+				return nil, err
+			}
+			zap.L().Warn("Rotated session cookie reused, session revoked", zap.String("session_id", id))
+			s.revokeRefreshToken(issuer, sealedRefresh)
+			return nil, ErrSessionReused
+		}
+	}
+
+	accessToken, err := OpenToken(s.cfg, sealedAccess)
+	if err != nil {
+		return nil, err
+	}
+	result := &SessionToken{SessionID: id, AccessToken: accessToken, ExpiresAt: expiresAt}
+
+	// A request racing a rotation keeps using the token it refreshed.
+	if !current || accessExpiresAt.Sub(now) > refreshMargin {
+		if _, err := tx.Exec(ctx,
+			"UPDATE sessions SET last_used_at=now() WHERE id=$1 AND last_used_at < now() - interval '1 minute'", id,
+		); err != nil {
+			return nil, err
+		}
+		return result, tx.Commit(ctx)
+	}
+
+	provider := s.providers.Get(issuer)
+	if sealedRefresh == nil || provider == nil {
+		return nil, ErrInvalidSession
+	}
+	refreshToken, err := OpenToken(s.cfg, sealedRefresh)
+	if err != nil {
+		return nil, err
+	}
+	token, err := provider.Refresh(ctx, refreshToken)
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		zap.L().Info("Session refresh rejected by issuer, session ended", zap.String("session_id", id), zap.Error(err))
+		if _, err := tx.Exec(ctx, "DELETE FROM sessions WHERE id=$1", id); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// With rotation enabled the issuer sends a new refresh token; without,
+	// the old one stays valid.
+	if token.RefreshToken != "" {
+		refreshToken = token.RefreshToken
+	}
+	if sealedAccess, err = SealToken(s.cfg, token.AccessToken); err != nil {
+		return nil, err
+	}
+	if sealedRefresh, err = SealToken(s.cfg, refreshToken); err != nil {
+		return nil, err
+	}
+	newSecret := sessionSecret(32)
+	result.AccessToken = token.AccessToken
+	result.ExpiresAt = now.Add(s.ttl)
+	result.Cookie = id + "." + newSecret
+	_, err = tx.Exec(ctx,
+		`UPDATE sessions SET token_hash=$2, previous_token_hash=$3, rotated_at=$4, access_token=$5, access_expires_at=$6,
+		        refresh_token=$7, last_used_at=$4, expires_at=$8
+		 WHERE id=$1`,
+		id, hashSessionSecret(newSecret), tokenHash, now, sealedAccess, accessExpiry(now, token), sealedRefresh, result.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// List returns userID's active sessions, most recently used first.
+// currentID marks the session of the request.
+func (s *SessionStore) List(ctx context.Context, userID, currentID string) ([]Session, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, issuer, user_agent, ip, created_at, last_used_at, expires_at FROM sessions
+		 WHERE user_id=$1 AND expires_at > now()
+		 ORDER BY last_used_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.Issuer, &sess.UserAgent, &sess.IP, &sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sess.Current = sess.ID == currentID
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends userID's session id and revokes its refresh token at the
+// issuer. It reports whether the session existed.
+func (s *SessionStore) Revoke(ctx context.Context, userID, id string) (bool, error) {
+	rows, err := db.Pool.Query(ctx, "DELETE FROM sessions WHERE id=$1 AND user_id=$2 RETURNING issuer, refresh_token", id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := s.revokeRows(rows)
+	return n > 0, err
+}
+
+// RevokeAll ends all of userID's sessions and returns how many there were.
+func (s *SessionStore) RevokeAll(ctx context.Context, userID string) (int, error) {
+	rows, err := db.Pool.Query(ctx, "DELETE FROM sessions WHERE user_id=$1 RETURNING issuer, refresh_token", userID)
+	if err != nil {
+		return 0, err
+	}
+	return s.revokeRows(rows)
+}
+
+// RevokeCookie ends the session identified by cookie, if the cookie is
+// still valid for it.
+func (s *SessionStore) RevokeCookie(ctx context.Context, cookie string) error {
+	id, secret, ok := strings.Cut(cookie, ".")
+	if !ok {
+		return nil
+	}
+	rows, err := db.Pool.Query(ctx,
+		"DELETE FROM sessions WHERE id=$1 AND (token_hash=$2 OR previous_token_hash=$2) RETURNING issuer, refresh_token",
+		id, hashSessionSecret(secret),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.revokeRows(rows)
+	return err
+}
+
+// revokeRows revokes the refresh tokens of deleted sessions at their
+// issuers in the background, so that they cannot be used outside the
+// session either.
+func (s *SessionStore) revokeRows(rows pgx.Rows) (int, error) {
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var issuer string
+		var sealed []byte
+		if err := rows.Scan(&issuer, &sealed); err != nil {
+			return n, err
+		}
+		n++
+		s.revokeRefreshToken(issuer, sealed)
+	}
+	return n, rows.Err()
+}
+
+func (s *SessionStore) revokeRefreshToken(issuer string, sealed []byte) {
+	provider := s.providers.Get(issuer)
+	if sealed == nil || provider == nil {
+		return
+	}
+	go func() {
+		token, err := OpenToken(s.cfg, sealed)
+		if err == nil {
+			err = provider.Revoke(context.Background(), token)
+		}
+		if err != nil {
+			zap.L().Warn("Failed to revoke refresh token", zap.Error(err))
+		}
+	}()
+}
+
+// accessExpiry is when an access token issued at now expires.
+func accessExpiry(now time.Time, token *TokenResponse) time.Time {
+	return now.Add(time.Duration(token.ExpiresIn) * time.Second)
+}
+
+func sessionSecret(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		zap.L().Fatal("crypto/rand failed", zap.Error(err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func hashSessionSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,79 @@
+package services
+
+import "encoding/json"
+
+// taskStreamParser picks complete task objects out of a JSON task array that
+// arrives in arbitrary fragments. It only tracks nesting and string state;
+// the full text is still decoded and validated once the stream ends.
+type taskStreamParser struct {
+	buf      []byte
+	pos      int
+	depth    int
+	inString bool
+	escaped  bool
+	start    int
+	count    int
+	onTask   func(int, Task) error
+}
+
+func newTaskStreamParser(onTask func(int, Task) error) *taskStreamParser {
+	return &taskStreamParser{onTask: onTask, start: -1}
+}
+
+// Write appends a fragment and reports every task object it completes.
+func (p *taskStreamParser) Write(fragment string) error {
+	p.buf = append(p.buf, fragment...)
+	for ; p.pos < len(p.buf); p.pos++ {
+		ch := p.buf[p.pos]
+		if p.inString {
+			switch {
+			case p.escaped:
+				p.escaped = false
+			case ch == '\\':
+				p.escaped = true
+			case ch == '"':
+				p.inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			p.inString = true
+		case '[', '{':
+			if ch == '{' && p.depth == 1 {
+				p.start = p.pos
+			}
+			p.depth++
+		case ']', '}':
+			p.depth--
+			if ch == '}' && p.depth == 1 && p.start >= 0 {
+				if err := p.emit(p.buf[p.start : p.pos+1]); err != nil {
+					p.pos++
+					return err
+				}
+				p.start = -1
+			}
+		}
+	}
+	return nil
+}
+
+// Text returns everything written so far.
+func (p *taskStreamParser) Text() string {
+	return string(p.buf)
+}
+
+func (p *taskStreamParser) emit(object []byte) error {
+	var t Task
+	if err := json.Unmarshal(object, &t); err != nil {
+		// Leave malformed elements to the final decode, which reports them.
+		return nil
+	}
+	if t.DependsOn == nil {
+		t.DependsOn = []string{}
+	}
+	index := p.count
+	p.count++
+	return p.onTask(index, t)
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// maxTemplatePrompt bounds the size of a prompt skeleton.
+const maxTemplatePrompt = 8000
+
+var variableName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type TemplateVariable struct {
+	Name        string `json:"name" validate:"required,max=50"`
+	Description string `json:"description,omitempty" validate:"max=500"`
+	Required    bool   `json:"required"`
+	Default     string `json:"default,omitempty" validate:"max=500"`
+}
+
+// PromptTemplate tailors generation to a kind of goal. Prompt is a
+// text/template skeleton that can reference {{.goal}} and every declared
+// variable by name and branch with {{if}}/{{else}}; loops, nested templates
+// and function calls are rejected. The task output format is appended
+// automatically.
+type PromptTemplate struct {
+	Prompt              string
+	Variables           []TemplateVariable
+	DefaultDurationDays int
+	SeedTasks           []Task
+}
+
+// TemplateError reports problems with a template definition or with the
+// variable values supplied for it.
+type TemplateError struct {
+	Problems []string
+}
+
+func (e *TemplateError) Error() string {
+	return "invalid template: " + strings.Join(e.Problems, "; ")
+}
+
+// Check validates the template definition: the skeleton must parse, declared
+// variable names must be unique identifiers, and seed tasks must form a valid
+// task list.
+func (t *PromptTemplate) Check() error {
+	var problems []string
+	if strings.TrimSpace(t.Prompt) == "" {
+		problems = append(problems, "prompt is required")
+	}
+	if len(t.Prompt) > maxTemplatePrompt {
+		problems = append(problems, fmt.Sprintf("prompt must be at most %d characters long", maxTemplatePrompt))
+	}
+
+	declared := map[string]bool{"goal": true}
+	for _, v := range t.Variables {
+		switch {
+		case !variableName.MatchString(v.Name):
+			problems = append(problems, fmt.Sprintf("variable %q must be lower case letters, digits and underscores", v.Name))
+		case declared[v.Name]:
+			problems = append(problems, fmt.Sprintf("variable %q is declared twice or is reserved", v.Name))
+		}
+		declared[v.Name] = true
+	}
+
+	// The fields come from every branch, so a typo in an {{else}} that no
+	// sample rendering would reach is caught here rather than at generation.
+	if _, fields, err := t.parse(); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		for _, name := range fields {
+			if !declared[name] {
+				problems = append(problems, fmt.Sprintf("prompt references undeclared variable %q", name))
+			}
+		}
+	}
+
+	if t.DefaultDurationDays < 0 || t.DefaultDurationDays > 365 {
+		problems = append(problems, "default_duration_days must be between 0 and 365")
+	}
+	if len(t.SeedTasks) > 0 {
+		seeds := t.seedTasks()
+		if err := ValidateTasks(seeds); err != nil {
+			problems = append(problems, "seed_tasks: "+err.Error())
+		} else if _, _, err := CheckDependencies(seeds, DependencyModeReject); err != nil {
+			problems = append(problems, "seed_tasks: "+err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return &TemplateError{Problems: problems}
+	}
+	return nil
+}
+
+// Render fills the skeleton with the goal and variable values. Declared
+// variables that are missing take their default; missing required variables
+// and undeclared values are errors.
+func (t *PromptTemplate) Render(goal string, values map[string]string) (string, error) {
+	declared := make(map[string]bool, len(t.Variables))
+	data := map[string]string{"goal": goal}
+	var problems []string
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if value == "" && v.Required {
+			problems = append(problems, fmt.Sprintf("variable %q is required", v.Name))
+		}
+		data[v.Name] = value
+	}
+	for name := range values {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("unknown variable %q", name))
+		}
+	}
+	if len(problems) > 0 {
+		return "", &TemplateError{Problems: problems}
+	}
+
+	tmpl, _, err := t.parse()
+	if err != nil {
+		return "", &TemplateError{Problems: []string{err.Error()}}
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", &TemplateError{Problems: []string{err.Error()}}
+	}
+	return out.String(), nil
+}
+
+// parse parses the skeleton and rejects anything beyond text, {{.name}}
+// fields and {{if}}/{{else}}, so a stored template cannot loop or call
+// functions when it is rendered. It also returns the names of the fields the
+// skeleton references, in every branch, in order of first use.
+func (t *PromptTemplate) parse() (*template.Template, []string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(t.Prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, nil, fmt.Errorf("template: prompt must not define nested templates")
+	}
+	w := &templateWalk{tree: tmpl.Tree, seen: map[string]bool{}}
+	if err := w.node(tmpl.Tree.Root); err != nil {
+		return nil, nil, err
+	}
+	return tmpl, w.fields, nil
+}
+
+// templateWalk checks the nodes of a parsed skeleton and collects the
+// fields they reference.
+type templateWalk struct {
+	tree   *parse.Tree
+	fields []string
+	seen   map[string]bool
+}
+
+func (w *templateWalk) node(node parse.Node) error {
+	switch n := node.(type) {
+	case nil, *parse.TextNode, *parse.CommentNode:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := w.node(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.ActionNode:
+		return w.pipe(n, n.Pipe)
+	case *parse.IfNode:
+		if err := w.pipe(n, n.Pipe); err != nil {
+			return err
+		}
+		if err := w.node(n.List); err != nil {
+			return err
+		}
+		return w.node(n.ElseList)
+	default:
+		return templateNodeError(w.tree, node)
+	}
+}
+
+// pipe accepts only a bare {{.name}} reference.
+func (w *templateWalk) pipe(node parse.Node, pipe *parse.PipeNode) error {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return templateNodeError(w.tree, node)
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return templateNodeError(w.tree, node)
+	}
+	if name := field.Ident[0]; !w.seen[name] {
+		w.seen[name] = true
+		w.fields = append(w.fields, name)
+	}
+	return nil
+}
+
+func templateNodeError(tree *parse.Tree, node parse.Node) error {
+	location, context := tree.ErrorContext(node)
+	return fmt.Errorf("template: %s: %q is not allowed; use {{.name}} and {{if}}/{{else}} only", location, context)
+}
+
+// seedTasks returns the seed tasks with the template's default duration
+// applied where none was given.
+func (t *PromptTemplate) seedTasks() []Task {
+	out := make([]Task, len(t.SeedTasks))
+	for i, s := range t.SeedTasks {
+		if s.DurationDays == 0 {
+			s.DurationDays = max(t.DefaultDurationDays, 1)
+		}
+		if s.DependsOn == nil {
+			s.DependsOn = []string{}
+		}
+		out[i] = s
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptTemplateCheck(t *testing.T) {
+	level := []TemplateVariable{{Name: "level"}}
+	tests := []struct {
+		name      string
+		tmpl      PromptTemplate
+		wantError string
+	}{
+		{name: "goal only", tmpl: PromptTemplate{Prompt: "Plan {{.goal}}."}},
+		{name: "declared variable", tmpl: PromptTemplate{Prompt: "Plan {{.goal}} at {{.level}}.", Variables: level}},
+		{name: "if else", tmpl: PromptTemplate{Prompt: "{{if .level}}At {{.level}}.{{else}}Any level.{{end}}", Variables: level}},
+		{name: "comment", tmpl: PromptTemplate{Prompt: "{{/* note */}}Plan {{.goal}}."}},
+		{name: "empty prompt", tmpl: PromptTemplate{Prompt: "  "}, wantError: "prompt is required"},
+		{name: "too long", tmpl: PromptTemplate{Prompt: strings.Repeat("x", maxTemplatePrompt+1)}, wantError: "at most"},
+		{name: "syntax error", tmpl: PromptTemplate{Prompt: "{{.goal"}, wantError: "unclosed action"},
+		{name: "undeclared variable", tmpl: PromptTemplate{Prompt: "{{.level}}"}, wantError: `undeclared variable "level"`},
+		{name: "undeclared in else", tmpl: PromptTemplate{Prompt: "{{if .level}}x{{else}}{{.typo}}{{end}}", Variables: level}, wantError: `undeclared variable "typo"`},
+		{name: "undeclared in condition", tmpl: PromptTemplate{Prompt: "{{if .team}}x{{end}}"}, wantError: `undeclared variable "team"`},
+		{name: "range", tmpl: PromptTemplate{Prompt: "{{range 1000000000}}x{{end}}"}, wantError: "not allowed"},
+		{name: "with", tmpl: PromptTemplate{Prompt: "{{with .goal}}{{.}}{{end}}"}, wantError: "not allowed"},
+		{name: "define", tmpl: PromptTemplate{Prompt: `{{define "x"}}y{{end}}{{.goal}}`}, wantError: "nested templates"},
+		{name: "template call", tmpl: PromptTemplate{Prompt: `{{template "prompt"}}`}, wantError: "not allowed"},
+		{name: "function call", tmpl: PromptTemplate{Prompt: `{{printf "%s" .goal}}`}, wantError: "not allowed"},
+		{name: "pipeline", tmpl: PromptTemplate{Prompt: "{{.goal | len}}"}, wantError: "not allowed"},
+		{name: "function in if", tmpl: PromptTemplate{Prompt: `{{if eq .goal "x"}}y{{end}}`}, wantError: "not allowed"},
+		{name: "variable declaration", tmpl: PromptTemplate{Prompt: "{{$g := .goal}}"}, wantError: "not allowed"},
+		{name: "nested field", tmpl: PromptTemplate{Prompt: "{{.goal.x}}"}, wantError: "not allowed"},
+		{name: "invalid variable name", tmpl: PromptTemplate{Prompt: "{{.goal}}", Variables: []TemplateVariable{{Name: "Level"}}}, wantError: "lower case"},
+		{name: "reserved variable", tmpl: PromptTemplate{Prompt: "{{.goal}}", Variables: []TemplateVariable{{Name: "goal"}}}, wantError: "reserved"},
+		{name: "duration out of range", tmpl: PromptTemplate{Prompt: "{{.goal}}", DefaultDurationDays: 400}, wantError: "default_duration_days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tmpl.Check()
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			var templateErr *TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Fatalf("Check() = %v, want *TemplateError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantError) {
+				t.Fatalf("Check() = %q, want it to mention %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPromptTemplateRender(t *testing.T) {
+	tmpl := PromptTemplate{
+		Prompt: "Plan {{.goal}}.{{if .budget}} Budget {{.budget}}.{{end}}",
+		Variables: []TemplateVariable{
+			{Name: "budget"},
+			{Name: "audience", Required: true},
+		},
+	}
+	got, err := tmpl.Render("a launch", map[string]string{"budget": "$5k", "audience": "devs"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "Plan a launch. Budget $5k."; got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+
+	if _, err := tmpl.Render("a launch", map[string]string{"budget": "$5k"}); err == nil || !strings.Contains(err.Error(), `"audience" is required`) {
+		t.Fatalf("Render() without required variable = %v", err)
+	}
+	if _, err := tmpl.Render("a launch", map[string]string{"audience": "devs", "extra": "x"}); err == nil || !strings.Contains(err.Error(), `unknown variable "extra"`) {
+		t.Fatalf("Render() with unknown variable = %v", err)
+	}
+
+	unsafe := PromptTemplate{Prompt: "{{range 1000000000}}x{{end}}"}
+	if _, err := unsafe.Render("goal", nil); err == nil {
+		t.Fatal("Render() of a range template succeeded")
+	}
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/KILLERGTG01/smart-task-planner-be/internal/db"
@@ -12,6 +13,13 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
 }
 
 func ValidateUser(user *db.User) error {
@@ -28,6 +36,34 @@ func ValidatePlan(plan *db.Plan) error {
 	return nil
 }
 
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// StructErrors validates s and returns one entry per failed field, or nil
+// when s is valid.
+func StructErrors(s interface{}) []FieldError {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return []FieldError{{Message: err.Error()}}
+	}
+
+	res := make([]FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		res = append(res, FieldError{
+			Field:   fieldError.Field(),
+			Message: formatFieldError(fieldError),
+		})
+	}
+	return res
+}
+
 func formatValidationError(err error) error {
 	var errorMessages []string
 
@@ -52,10 +88,55 @@ func formatFieldError(fieldError validator.FieldError) string {
 	case "uuid4":
 		return fmt.Sprintf("%s must be a valid UUID", field)
 	case "min":
+		if isNumeric(fieldError.Kind()) {
+			return fmt.Sprintf("%s must be at least %s", field, fieldError.Param())
+		}
 		return fmt.Sprintf("%s must be at least %s characters long", field, fieldError.Param())
 	case "max":
+		if isNumeric(fieldError.Kind()) {
+			return fmt.Sprintf("%s must be at most %s", field, fieldError.Param())
+		}
 		return fmt.Sprintf("%s must be at most %s characters long", field, fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fieldError.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", field, fieldError.Param())
+	case "gtefield":
+		return fmt.Sprintf("%s must not be less than %s", field, snakeCase(fieldError.Param()))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fieldError.Param(), " ", ", "))
+	case "datetime":
+		return fmt.Sprintf("%s must be a date in the format YYYY-MM-DD", field)
+	case "bcp47_language_tag":
+		return fmt.Sprintf("%s must be a BCP 47 language tag such as en or pt-BR", field)
 	default:
 		return fmt.Sprintf("%s failed validation for tag '%s'", field, tag)
 	}
 }
+
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// snakeCase turns a Go field name into the json name used for it, for
+// messages that refer to another field.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
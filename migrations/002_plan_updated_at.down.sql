@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS plans_set_updated_at ON plans;
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,23 @@
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS plan_json JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+UPDATE plans p SET plan_json = COALESCE((
+  SELECT jsonb_agg(
+    jsonb_build_object(
+      'task', t.title,
+      'duration_days', t.duration_days,
+      'depends_on', COALESCE((
+        SELECT jsonb_agg(d.title ORDER BY d.position)
+        FROM task_dependencies td
+        JOIN tasks d ON d.id = td.depends_on_id
+        WHERE td.task_id = t.id
+      ), '[]'::jsonb)
+    ) ORDER BY t.position
+  )
+  FROM tasks t
+  WHERE t.plan_id = p.id
+), '[]'::jsonb);
+
+ALTER TABLE plans ALTER COLUMN plan_json DROP DEFAULT;
+
+DROP TABLE IF EXISTS task_dependencies;
+DROP TABLE IF EXISTS tasks;
@@ -0,0 +1,58 @@
+CREATE TABLE IF NOT EXISTS tasks (
+  id TEXT PRIMARY KEY,
+  plan_id TEXT NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  duration_days INTEGER NOT NULL DEFAULT 1,
+  status TEXT NOT NULL DEFAULT 'todo',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_plan ON tasks(plan_id, position);
+
+CREATE TABLE IF NOT EXISTS task_dependencies (
+  task_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  depends_on_id TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  PRIMARY KEY (task_id, depends_on_id),
+  CHECK (task_id <> depends_on_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on_id);
+
+DROP TRIGGER IF EXISTS tasks_set_updated_at ON tasks;
+CREATE TRIGGER tasks_set_updated_at
+  BEFORE UPDATE ON tasks
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Backfill tasks from the legacy plan_json arrays, keeping array order as position.
+INSERT INTO tasks (id, plan_id, position, title, duration_days, created_at, updated_at)
+SELECT
+  gen_random_uuid()::text,
+  p.id,
+  e.ord - 1,
+  COALESCE(e.elem->>'task', ''),
+  GREATEST(COALESCE(CEIL((e.elem->>'duration_days')::numeric)::integer, 1), 1),
+  p.created_at,
+  p.updated_at
+FROM plans p
+CROSS JOIN LATERAL jsonb_array_elements(
+  CASE WHEN jsonb_typeof(p.plan_json) = 'array' THEN p.plan_json ELSE '[]'::jsonb END
+) WITH ORDINALITY AS e(elem, ord);
+
+-- Dependencies were stored by task name; resolve them within the same plan.
+INSERT INTO task_dependencies (task_id, depends_on_id)
+SELECT DISTINCT t.id, d.id
+FROM plans p
+CROSS JOIN LATERAL jsonb_array_elements(
+  CASE WHEN jsonb_typeof(p.plan_json) = 'array' THEN p.plan_json ELSE '[]'::jsonb END
+) WITH ORDINALITY AS e(elem, ord)
+JOIN tasks t ON t.plan_id = p.id AND t.position = e.ord - 1
+CROSS JOIN LATERAL jsonb_array_elements_text(
+  CASE WHEN jsonb_typeof(e.elem->'depends_on') = 'array' THEN e.elem->'depends_on' ELSE '[]'::jsonb END
+) AS dep(name)
+JOIN tasks d ON d.plan_id = p.id AND d.title = dep.name
+WHERE d.id <> t.id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE plans DROP COLUMN IF EXISTS plan_json;
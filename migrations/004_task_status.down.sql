@@ -0,0 +1,4 @@
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks
+  DROP COLUMN IF EXISTS completed_at,
+  DROP COLUMN IF EXISTS started_at;
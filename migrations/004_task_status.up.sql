@@ -0,0 +1,9 @@
+UPDATE tasks SET status = 'todo' WHERE status NOT IN ('todo', 'in_progress', 'blocked', 'done');
+
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+  CHECK (status IN ('todo', 'in_progress', 'blocked', 'done'));
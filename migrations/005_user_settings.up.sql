@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS user_settings (
+  user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  working_days TEXT[] NOT NULL DEFAULT ARRAY['sun', 'mon', 'tue', 'wed', 'thu', 'fri', 'sat'],
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+DROP TRIGGER IF EXISTS user_settings_set_updated_at ON user_settings;
+CREATE TRIGGER user_settings_set_updated_at
+  BEFORE UPDATE ON user_settings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS user_holidays (
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  date DATE NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (user_id, date)
+);
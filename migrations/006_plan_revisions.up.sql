@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS plan_revisions (
+  plan_id TEXT NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+  revision INTEGER NOT NULL,
+  title TEXT,
+  goal TEXT,
+  tasks JSONB NOT NULL,
+  instruction TEXT,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  PRIMARY KEY (plan_id, revision)
+);
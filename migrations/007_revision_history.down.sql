@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS plan_revisions_immutable ON plan_revisions;
+DROP FUNCTION IF EXISTS reject_update();
+
+ALTER TABLE plan_revisions DROP CONSTRAINT IF EXISTS plan_revisions_reason_check;
+ALTER TABLE plan_revisions
+  DROP COLUMN IF EXISTS restored_from,
+  DROP COLUMN IF EXISTS reason,
+  DROP COLUMN IF EXISTS author;
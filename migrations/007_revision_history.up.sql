@@ -0,0 +1,26 @@
+ALTER TABLE plan_revisions
+  ADD COLUMN IF NOT EXISTS author TEXT,
+  ADD COLUMN IF NOT EXISTS reason TEXT,
+  ADD COLUMN IF NOT EXISTS restored_from INTEGER;
+
+UPDATE plan_revisions SET reason = CASE WHEN instruction IS NULL THEN 'initial' ELSE 'refine' END
+  WHERE reason IS NULL;
+UPDATE plan_revisions r SET author = p.user_id
+  FROM plans p
+  WHERE p.id = r.plan_id AND r.reason = 'refine' AND r.author IS NULL;
+
+ALTER TABLE plan_revisions ALTER COLUMN reason SET NOT NULL;
+ALTER TABLE plan_revisions DROP CONSTRAINT IF EXISTS plan_revisions_reason_check;
+ALTER TABLE plan_revisions ADD CONSTRAINT plan_revisions_reason_check
+  CHECK (reason IN ('initial', 'generate', 'edit', 'task_update', 'refine', 'restore'));
+
+CREATE OR REPLACE FUNCTION reject_update() RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION '% rows are immutable', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS plan_revisions_immutable ON plan_revisions;
+CREATE TRIGGER plan_revisions_immutable
+  BEFORE UPDATE ON plan_revisions
+  FOR EACH ROW EXECUTE FUNCTION reject_update();
@@ -0,0 +1,10 @@
+-- Subtasks cannot be represented without parent_id; keep the top-level tasks.
+DELETE FROM tasks WHERE parent_id IS NOT NULL;
+
+-- Existing 'expand' revisions are kept; NOT VALID skips checking them.
+ALTER TABLE plan_revisions DROP CONSTRAINT IF EXISTS plan_revisions_reason_check;
+ALTER TABLE plan_revisions ADD CONSTRAINT plan_revisions_reason_check
+  CHECK (reason IN ('initial', 'generate', 'edit', 'task_update', 'refine', 'restore')) NOT VALID;
+
+DROP INDEX IF EXISTS idx_tasks_parent;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_id;
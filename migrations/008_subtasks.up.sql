@@ -0,0 +1,8 @@
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS parent_id TEXT REFERENCES tasks(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks(parent_id);
+
+ALTER TABLE plan_revisions DROP CONSTRAINT IF EXISTS plan_revisions_reason_check;
+ALTER TABLE plan_revisions ADD CONSTRAINT plan_revisions_reason_check
+  CHECK (reason IN ('initial', 'generate', 'edit', 'task_update', 'refine', 'restore', 'expand'));
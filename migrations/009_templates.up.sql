@@ -0,0 +1,51 @@
+-- Templates with a NULL user_id are built in and visible to everyone.
+CREATE TABLE IF NOT EXISTS templates (
+  id TEXT PRIMARY KEY,
+  user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  prompt TEXT NOT NULL,
+  variables JSONB NOT NULL DEFAULT '[]'::jsonb,
+  default_duration_days INTEGER NOT NULL DEFAULT 0,
+  seed_tasks JSONB NOT NULL DEFAULT '[]'::jsonb,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_templates_user ON templates(user_id);
+
+DROP TRIGGER IF EXISTS templates_set_updated_at ON templates;
+CREATE TRIGGER templates_set_updated_at
+  BEFORE UPDATE ON templates
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO templates (id, user_id, name, description, prompt, variables, default_duration_days, seed_tasks)
+VALUES
+(
+  'learn-skill',
+  NULL,
+  'Learn a skill',
+  'Study plan for picking up a language, framework or other skill.',
+  'Create a study plan for this learning goal: "{{.goal}}".
+The learner''s current level is {{.level}} and they can study about {{.hours_per_week}} hours per week.
+Alternate learning new material with hands-on practice, and finish with a project that applies the skill.',
+  '[{"name": "level", "description": "Current experience level", "required": false, "default": "beginner"},
+    {"name": "hours_per_week", "description": "Study time available per week", "required": false, "default": "5"}]'::jsonb,
+  3,
+  '[]'::jsonb
+),
+(
+  'launch-product',
+  NULL,
+  'Launch a product',
+  'From validation to launch for a software product.',
+  'Create a launch plan for this product goal: "{{.goal}}".
+The target audience is {{.audience}}.{{if .budget}} The budget is {{.budget}}.{{end}}
+Cover customer validation, building the minimum viable product, testing, marketing and the launch itself.',
+  '[{"name": "audience", "description": "Who the product is for", "required": true},
+    {"name": "budget", "description": "Available budget", "required": false}]'::jsonb,
+  5,
+  '[{"task": "Interview potential customers", "duration_days": 5, "depends_on": []},
+    {"task": "Launch publicly", "duration_days": 1, "depends_on": []}]'::jsonb
+)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS generation_cache (
+  key TEXT PRIMARY KEY,
+  tasks JSONB NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_generation_cache_expires_at ON generation_cache(expires_at);
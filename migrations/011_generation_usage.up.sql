@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS generation_usage (
+  subject TEXT NOT NULL,
+  day DATE NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (subject, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_generation_usage_day ON generation_usage(day);
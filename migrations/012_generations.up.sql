@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS generations (
+  id BIGSERIAL PRIMARY KEY,
+  user_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+  plan_id TEXT REFERENCES plans(id) ON DELETE SET NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('generate', 'refine', 'expand')),
+  source TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  model TEXT NOT NULL,
+  prompt_tokens INTEGER NOT NULL DEFAULT 0,
+  completion_tokens INTEGER NOT NULL DEFAULT 0,
+  total_tokens INTEGER NOT NULL DEFAULT 0,
+  latency_ms INTEGER NOT NULL DEFAULT 0,
+  cost_usd NUMERIC(12, 6) NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_generations_created_at ON generations(created_at);
+CREATE INDEX IF NOT EXISTS idx_generations_user ON generations(user_id, created_at);
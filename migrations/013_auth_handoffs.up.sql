@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS auth_handoffs (
+  code_hash TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  access_token BYTEA NOT NULL,
+  refresh_token BYTEA,
+  token_type TEXT NOT NULL,
+  expires_in INTEGER NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_handoffs_expires_at ON auth_handoffs(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE auth_handoffs DROP COLUMN IF EXISTS issuer;
+ALTER TABLE sessions DROP COLUMN IF EXISTS issuer;
@@ -0,0 +1,4 @@
+-- Sessions and handoffs remember the issuer that granted their tokens.
+-- Rows from before multiple issuers were supported all came from Auth0.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS issuer TEXT NOT NULL DEFAULT 'auth0';
+ALTER TABLE auth_handoffs ADD COLUMN IF NOT EXISTS issuer TEXT NOT NULL DEFAULT 'auth0';